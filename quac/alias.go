@@ -17,6 +17,11 @@ const (
 	KindImage     = idea.KindImage
 	KindAudio     = idea.KindAudio
 	KindEnText    = idea.KindEnText
+
+	RelSupports     = idea.RelSupports
+	RelContradicts  = idea.RelContradicts
+	RelExtends      = idea.RelExtends
+	RelPrerequisite = idea.RelPrerequisite
 )
 
 var (
@@ -25,6 +30,7 @@ var (
 	GetIdByFilename          = idea.GetIdByFilename
 	GetNextID                = idea.GetNextID
 	IncrementID              = idea.IncrementID
+	EnsureIDCounterAtLeast   = idea.EnsureIDCounterAtLeast
 	ParseID                  = idea.ParseID
 	ParseIDNoLogLast         = idea.ParseIDNoLogLast
 	ParseIDOp                = idea.ParseIDOp
@@ -49,15 +55,37 @@ var (
 	NewTagAll                = idea.NewTagAll
 	NewTagContains           = idea.NewTagContains
 	NewTagDates              = idea.NewTagDates
+	NewTagLineage            = idea.NewTagLineage
+	NewLineage               = idea.NewLineage
+	NewTagRelation           = idea.NewTagRelation
+	NewRelation              = idea.NewRelation
+	ValidateRelationType     = idea.ValidateRelationType
+	ParseRelation            = idea.ParseRelation
+	GetAllRelations          = idea.GetAllRelations
+	WriteRelations           = idea.WriteRelations
 	ParseTagFromString       = idea.ParseTagFromString
 	ParseFirstTagFromString  = idea.ParseFirstTagFromString
+	SanitizeTag              = idea.SanitizeTag
 	ConcatAllContentFromTags = idea.ConcatAllContentFromTags
 	ParseClumpedTags         = idea.ParseClumpedTags
+	SplitClumpedTags         = idea.SplitClumpedTags
 	ParseStringTags          = idea.ParseStringTags
 	CombineClumpedTags       = idea.CombineClumpedTags
 	TodayDate                = idea.TodayDate
 	GetKind                  = idea.GetKind
+	KindName                 = idea.KindName
+	CycleName                = idea.CycleName
+	ParseCycleName           = idea.ParseCycleName
+	ParseLinks               = idea.ParseLinks
+	ReplaceLinks             = idea.ReplaceLinks
 	IdStr                    = idea.IdStr
+	IsNativeEncrypted        = idea.IsNativeEncrypted
+	IsLegacyEncrypted        = idea.IsLegacyEncrypted
+	Encrypt                  = idea.Encrypt
+	Decrypt                  = idea.Decrypt
+	DeriveKey                = idea.DeriveKey
+	SealWithKey              = idea.SealWithKey
+	OpenWithKey              = idea.OpenWithKey
 
 	// variable aliases
 	WithoutKeyword       = idea.WithoutKeyword
@@ -75,9 +103,17 @@ var (
 	EditedDatesKeyword   = idea.EditedDatesKeyword
 	ConsumedDateKeyword  = idea.ConsumedDateKeyword
 	ConsumedDatesKeyword = idea.ConsumedDatesKeyword
+	DescendsFromKeyword  = idea.DescendsFromKeyword
+	AncestorOfKeyword    = idea.AncestorOfKeyword
+	RelationKeyword      = idea.RelationKeyword
+	RelationTypes        = idea.RelationTypes
 	IdeasDir             = idea.IdeasDir
 	ConfigFile           = idea.ConfigFile
 	LastIdFile           = idea.LastIdFile
+	RelationsFile        = idea.RelationsFile
+	IdBlocksFile         = idea.IdBlocksFile
+	CryptMagic           = idea.CryptMagic
+	ErrWrongPassphrase   = idea.ErrWrongPassphrase
 )
 
 type (
@@ -90,4 +126,10 @@ type (
 	TagAll      = idea.TagAll
 	TagContains = idea.TagContains
 	TagDates    = idea.TagDates
+	TagLineage  = idea.TagLineage
+	Lineage     = idea.Lineage
+	TagRelation = idea.TagRelation
+	Relation    = idea.Relation
+	Relations   = idea.Relations
+	IdBlock     = idea.IdBlock
 )
@@ -0,0 +1,135 @@
+package quac
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// audio-player= and audio-recorder= within the thranch config. Known players
+// (afplay, aplay, paplay, ffplay, mpv) and recorders (arecord, parecord, rec,
+// ffmpeg) are given their needed arguments, any other command is run with the
+// audio path appended. When unset the first known command found is used.
+var AudioPlayer, AudioRecorder string
+
+var (
+	audioPlayers   = []string{"afplay", "paplay", "aplay", "ffplay", "mpv"}
+	audioRecorders = []string{"arecord", "parecord", "rec", "ffmpeg"}
+)
+
+// find the configured command otherwise the first known command on the path
+func audioCommand(configured string, known []string, kind string) (bin string, args []string, err error) {
+	if fields := strings.Fields(configured); len(fields) > 0 {
+		return fields[0], fields[1:], nil
+	}
+	for _, bin := range known {
+		if _, err := exec.LookPath(bin); err == nil {
+			return bin, nil, nil
+		}
+	}
+	return "", nil, fmt.Errorf("no audio %v found (tried %v), set one with audio-%v= in the thranch config",
+		kind, strings.Join(known, ", "), kind)
+}
+
+// PlayAudio plays the audio file through the audio player
+func PlayAudio(audioPath string) error {
+	bin, args, err := audioCommand(AudioPlayer, audioPlayers, "player")
+	if err != nil {
+		return err
+	}
+	switch path.Base(bin) {
+	case "aplay":
+		args = append(args, "-q")
+	case "ffplay":
+		args = append(args, "-nodisp", "-autoexit", "-loglevel", "quiet")
+	case "mpv":
+		args = append(args, "--no-video", "--really-quiet")
+	}
+	cmd := exec.Command(bin, append(args, audioPath)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// RecordAudio records a wav file through the audio recorder until enter is
+// pressed
+func RecordAudio(audioPath string) error {
+	bin, args, err := audioCommand(AudioRecorder, audioRecorders, "recorder")
+	if err != nil {
+		return err
+	}
+	switch path.Base(bin) {
+	case "arecord":
+		args = append(args, "-q", "-f", "cd", "-t", "wav")
+	case "parecord":
+		args = append(args, "--file-format=wav")
+	case "rec":
+		args = append(args, "-q")
+	case "ffmpeg":
+		input := []string{"-f", "pulse", "-i", "default"}
+		if runtime.GOOS == "darwin" {
+			input = []string{"-f", "avfoundation", "-i", ":0"}
+		}
+		args = append(append(args, "-loglevel", "error", "-nostdin", "-y"), input...)
+	}
+
+	// the recorder does not read the console so that enter stops it
+	cmd := exec.Command(bin, append(args, audioPath)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	fmt.Println("recording... press enter to stop")
+	stop := make(chan struct{})
+	go func() {
+		consoleScanner := bufio.NewScanner(os.Stdin)
+		_ = consoleScanner.Scan()
+		close(stop)
+	}()
+
+	select {
+	case err = <-done:
+		// the recorder stopped by itself, enter is still awaited so that it is
+		// not taken from whatever reads the console next
+		fmt.Println("the recorder stopped, press enter to continue")
+		<-stop
+	case <-stop:
+		// recorders finish writing the file when interrupted
+		_ = cmd.Process.Signal(os.Interrupt)
+		err = <-done
+	}
+	fi, statErr := os.Stat(audioPath)
+	if statErr != nil || fi.Size() == 0 {
+		_ = os.Remove(audioPath)
+		if err == nil {
+			err = errors.New("nothing was recorded")
+		}
+		return fmt.Errorf("recording failed: %v", err)
+	}
+	return nil
+}
+
+// RecordAudioEntry records a new audio idea with the tags
+func RecordAudioEntry(clumpedTags string) (filepath string, err error) {
+	filepath, id := NewEmptyAudioEntry(clumpedTags)
+	if err := RecordAudio(filepath); err != nil {
+		return "", unusedRecordingIdErr(err, id)
+	}
+	return filepath, nil
+}
+
+// the id of a failed recording was already reserved
+func unusedRecordingIdErr(err error, id uint32) error {
+	return fmt.Errorf("%v (id %v was reserved for the recording and is left unused)", err, idea.IdStr(id))
+}
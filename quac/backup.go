@@ -0,0 +1,348 @@
+package quac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// Each backup is a snapshot directory (named by the time it was taken) within
+// the backup destination. Files which are unchanged since the previous
+// snapshot are hard-linked to it so that only changes take up space. Every
+// snapshot holds a manifest in the format of sha256sum (checksum, two spaces,
+// path) so that it may also be checked with `sha256sum -c manifest`.
+const (
+	BackupManifestName   = "manifest"
+	backupSnapshotLayout = "2006-01-02_150405"
+	backupTmpPrefix      = ".tmp-"
+)
+
+// files of the ranch (relative to QuDir) which are backed up besides the
+// contents of the ideas, trash and ocr directories
+var backupRanchFiles = []string{"config", "last", "relations", "id_blocks", "clippings_imported"}
+
+type BackupSnapshot struct {
+	Name  string
+	Dir   string
+	Files int
+}
+
+// checksums of a snapshot by path relative to the snapshot
+type backupManifest map[string]string
+
+func fileChecksum(filepath string) (string, error) {
+	bz, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func readBackupManifest(snapshotDir string) (backupManifest, error) {
+	lines, err := cmn.ReadLines(path.Join(snapshotDir, BackupManifestName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := make(backupManifest)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		split := strings.SplitN(line, "  ", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("bad manifest line in %v: %v", snapshotDir, line)
+		}
+		manifest[split[1]] = split[0]
+	}
+	return manifest, nil
+}
+
+func writeBackupManifest(snapshotDir string, manifest backupManifest) error {
+	var relPaths []string
+	for relPath := range manifest {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+	lines := make([]string, len(relPaths))
+	for i, relPath := range relPaths {
+		lines[i] = manifest[relPath] + "  " + relPath
+	}
+	return cmn.WriteLines(lines, path.Join(snapshotDir, BackupManifestName))
+}
+
+// the files of the ranch to backup, relative to QuDir. Within vault mode the
+// encrypted vault is backed up rather than the ideas, trash and ocr drafts.
+func backupRanchPaths() (relPaths []string, err error) {
+	dirs := []string{"ideas", "trash", "ocr"}
+	if VaultMode {
+		relPaths, err = vaultBackupPaths()
+		if err != nil {
+			return relPaths, err
+		}
+		dirs = nil
+	}
+	for _, dir := range dirs {
+		fis, err := ioutil.ReadDir(path.Join(QuDir, dir))
+		if os.IsNotExist(err) {
+			continue // the ocr directory only exists once a draft is saved
+		}
+		if err != nil {
+			return relPaths, err
+		}
+		for _, fi := range fis {
+			ext := path.Ext(fi.Name())
+			if fi.IsDir() || ext == ".swp" || ext == ".swo" {
+				continue
+			}
+			relPaths = append(relPaths, path.Join(dir, fi.Name()))
+		}
+	}
+	for _, relPath := range backupRanchFiles {
+		if cmn.FileExists(path.Join(QuDir, relPath)) {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	return relPaths, nil
+}
+
+// ListBackups returns the snapshots within the backup destination from oldest
+// to newest
+func ListBackups(destDir string) (snapshots []BackupSnapshot, err error) {
+	fis, err := ioutil.ReadDir(destDir)
+	if err != nil {
+		return snapshots, err
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), backupTmpPrefix) {
+			continue
+		}
+		dir := path.Join(destDir, fi.Name())
+		manifest, err := readBackupManifest(dir)
+		if err != nil {
+			continue // not a snapshot
+		}
+		snapshots = append(snapshots, BackupSnapshot{fi.Name(), dir, len(manifest)})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots, nil
+}
+
+// Backup takes a new snapshot of the ranch within the backup destination,
+// returning the snapshot along with the number of files which were linked to
+// the previous snapshot rather than copied.
+func Backup(destDir string) (snapshot BackupSnapshot, linked int, err error) {
+	if err := os.MkdirAll(destDir, os.ModePerm); err != nil {
+		return snapshot, 0, err
+	}
+	snapshots, err := ListBackups(destDir)
+	if err != nil {
+		return snapshot, 0, err
+	}
+
+	// unchanged files are found by checksum so renamed ideas are linked too
+	prevByChecksum := make(map[string]string)
+	if len(snapshots) > 0 {
+		prev := snapshots[len(snapshots)-1]
+		prevManifest, err := readBackupManifest(prev.Dir)
+		if err != nil {
+			return snapshot, 0, err
+		}
+		for relPath, checksum := range prevManifest {
+			prevByChecksum[checksum] = path.Join(prev.Dir, relPath)
+		}
+	}
+
+	name := time.Now().Format(backupSnapshotLayout)
+	snapshot = BackupSnapshot{Name: name, Dir: path.Join(destDir, name)}
+	if cmn.FileExists(snapshot.Dir) {
+		return snapshot, 0, fmt.Errorf("snapshot %v already exists", name)
+	}
+
+	// the snapshot is built under a temporary name so that an interrupted
+	// backup is never mistaken for a complete snapshot
+	tmpDir := path.Join(destDir, backupTmpPrefix+name)
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return snapshot, 0, err
+	}
+	relPaths, err := backupRanchPaths()
+	if err != nil {
+		return snapshot, 0, err
+	}
+	manifest := make(backupManifest)
+	for _, relPath := range relPaths {
+		src := path.Join(QuDir, relPath)
+		checksum, err := fileChecksum(src)
+		if err != nil {
+			return snapshot, linked, err
+		}
+		dst := path.Join(tmpDir, relPath)
+		if err := os.MkdirAll(path.Dir(dst), os.ModePerm); err != nil {
+			return snapshot, linked, err
+		}
+		if prevPath, found := prevByChecksum[checksum]; found && os.Link(prevPath, dst) == nil {
+			linked++
+		} else if err := cmn.Copy(src, dst); err != nil {
+			return snapshot, linked, err
+		}
+		manifest[relPath] = checksum
+	}
+	if err := writeBackupManifest(tmpDir, manifest); err != nil {
+		return snapshot, linked, err
+	}
+	snapshot.Files = len(manifest)
+	return snapshot, linked, os.Rename(tmpDir, snapshot.Dir)
+}
+
+// GetBackupSnapshot finds a snapshot by name within the backup destination,
+// the snapshot may also be provided as the path to a snapshot directory
+func GetBackupSnapshot(destDir, snapshot string) (BackupSnapshot, error) {
+	dir := snapshot
+	if !strings.Contains(snapshot, "/") {
+		dir = path.Join(destDir, snapshot)
+	}
+	manifest, err := readBackupManifest(dir)
+	if err != nil {
+		return BackupSnapshot{}, fmt.Errorf("no snapshot found at %v", dir)
+	}
+	return BackupSnapshot{path.Base(dir), dir, len(manifest)}, nil
+}
+
+// Ideas returns the ideas within the snapshot, note that the Path of each
+// idea is that within the ranch
+func (s BackupSnapshot) Ideas() (ideas idea.Ideas, err error) {
+	fis, err := ioutil.ReadDir(path.Join(s.Dir, "ideas"))
+	if err != nil {
+		return ideas, err
+	}
+	for _, fi := range fis {
+		if fi.IsDir() || path.Ext(fi.Name()) == ".swp" {
+			continue
+		}
+		ideas = append(ideas, idea.NewIdeaFromFilename(fi.Name(), false))
+	}
+	return ideas, nil
+}
+
+// verify the checksum of a file of the snapshot and copy it into the ranch,
+// files are always copied (never linked) so that the snapshot is unaffected
+// by later edits
+func (s BackupSnapshot) restoreFile(manifest backupManifest, relPath string) error {
+	src := path.Join(s.Dir, relPath)
+	checksum, err := fileChecksum(src)
+	if err != nil {
+		return err
+	}
+	if checksum != manifest[relPath] {
+		return fmt.Errorf("checksum mismatch for %v within snapshot %v", relPath, s.Name)
+	}
+	dst := path.Join(QuDir, relPath)
+	if err := os.MkdirAll(path.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	return cmn.Copy(src, dst)
+}
+
+// RestoreBackup restores the entire ranch from the snapshot, ideas and trash
+// not within the snapshot are removed. Every file is verified against the
+// manifest before anything within the ranch is changed.
+func RestoreBackup(s BackupSnapshot) (restored int, err error) {
+	if VaultMode && !VaultLocked {
+		return 0, errors.New("the vault must be locked to restore it")
+	}
+	manifest, err := readBackupManifest(s.Dir)
+	if err != nil {
+		return 0, err
+	}
+	for relPath, checksum := range manifest {
+		actual, err := fileChecksum(path.Join(s.Dir, relPath))
+		if err != nil {
+			return 0, err
+		}
+		if actual != checksum {
+			return 0, fmt.Errorf("checksum mismatch for %v within snapshot %v", relPath, s.Name)
+		}
+	}
+
+	relPaths, err := backupRanchPaths()
+	if err != nil {
+		return 0, err
+	}
+	for _, relPath := range relPaths {
+		if _, found := manifest[relPath]; !found && path.Dir(relPath) != "." {
+			if err := os.Remove(path.Join(QuDir, relPath)); err != nil {
+				return restored, err
+			}
+		}
+	}
+	for relPath := range manifest {
+		if err := s.restoreFile(manifest, relPath); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// RestoreBackupIdeas restores only the provided ideas of the snapshot, any
+// idea within the ranch with the same id is replaced.
+func RestoreBackupIdeas(s BackupSnapshot, idears idea.Ideas) error {
+	if VaultMode {
+		return errors.New("ideas cannot be restored individually within vault mode")
+	}
+	manifest, err := readBackupManifest(s.Dir)
+	if err != nil {
+		return err
+	}
+	byId := make(map[uint32]idea.Idea)
+	for _, idear := range GetAllIdeas() {
+		byId[idear.Id] = idear
+	}
+	for _, idear := range idears {
+		relPath := path.Join("ideas", idear.Filename)
+		if _, found := manifest[relPath]; !found {
+			return errors.New("idea not within the snapshot manifest: " + idear.Filename)
+		}
+		if err := s.restoreFile(manifest, relPath); err != nil {
+			return err
+		}
+		if existing, found := byId[idear.Id]; found && existing.Filename != idear.Filename {
+			if err := os.Remove(existing.Path()); err != nil {
+				return err
+			}
+		}
+		idea.EnsureIDCounterAtLeast(idear.Id)
+	}
+	return nil
+}
+
+// GetBackupDir returns the destination of the last backup taken, or if no
+// backup has been taken the backup-dir of the thranch config
+func GetBackupDir() string {
+	if cmn.FileExists(BackupDestFile) {
+		lines, err := cmn.ReadLines(BackupDestFile)
+		if err == nil && len(lines) > 0 && lines[0] != "" {
+			return lines[0]
+		}
+	}
+	return BackupDir
+}
+
+func SetBackupDir(destDir string) error {
+	absDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	return cmn.WriteLines([]string{absDir}, BackupDestFile)
+}
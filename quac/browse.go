@@ -77,7 +77,12 @@ func GetAssociations(idears idea.Ideas, tags []idea.Tag, searchForFilenames bool
 	if len(subset) == 1 || searchForFilenames {
 		return associatedTags, subset
 	}
+	return associatedTagCounts(subset, tags), outIdears
+}
 
+// the number of ideas within the subset with each tag, not counting the input
+// tags or the highlighted tags
+func associatedTagCounts(subset idea.Ideas, tags []idea.Tag) PairList {
 	at := make(map[string]int)
 	for _, idea := range subset {
 		for _, tag := range idea.Tags {
@@ -102,7 +107,7 @@ func GetAssociations(idears idea.Ideas, tags []idea.Tag, searchForFilenames bool
 			}
 		}
 	}
-	return rankByWordCount(at), outIdears
+	return rankByWordCount(at)
 }
 
 var highlighted []string
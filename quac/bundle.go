@@ -0,0 +1,223 @@
+package quac
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// A bundle is a gzipped tar containing a manifest along with the file of each
+// idea, it is self contained such that it can be imported into another ranch.
+const (
+	bundleFormat       = "thranch-bundle"
+	bundleVersion      = 1
+	bundleManifestName = "manifest.json"
+	bundleIdeasDir     = "ideas"
+)
+
+type BundleManifest struct {
+	Format    string           `json:"format"`
+	Version   int              `json:"version"`
+	Created   string           `json:"created"`
+	Ideas     []IdeaRecord     `json:"ideas"`
+	Relations []BundleRelation `json:"relations"`
+}
+
+type BundleRelation struct {
+	From uint32 `json:"from"`
+	Type string `json:"type"`
+	To   uint32 `json:"to"`
+}
+
+// BundleClosure returns the ideas along with every idea they have consumed (at
+// any depth) so that all consumes ids within the bundle can be resolved
+func BundleClosure(idears idea.Ideas) (closure idea.Ideas) {
+	lin := idea.NewLineage(GetAllIdeas())
+	included := make(map[uint32]bool)
+	for _, idear := range idears {
+		included[idear.Id] = true
+		for id := range lin.Ancestors(idear.Id) {
+			included[id] = true
+		}
+	}
+	for id := range included {
+		if idear, found := lin.Get(id); found {
+			closure = append(closure, idear)
+		}
+	}
+	sort.Slice(closure, func(i, j int) bool { return closure[i].Id < closure[j].Id })
+	return closure
+}
+
+// ExportBundle writes the ideas and their lineage closure as a bundle
+func ExportBundle(idears idea.Ideas, w io.Writer) (bundled idea.Ideas, err error) {
+	bundled = BundleClosure(idears)
+	manifest := BundleManifest{
+		Format:    bundleFormat,
+		Version:   bundleVersion,
+		Created:   idea.TodayDate().Format(cmn.LayoutYYYYdMMdDD),
+		Ideas:     []IdeaRecord{},
+		Relations: []BundleRelation{},
+	}
+	inBundle := make(map[uint32]bool)
+	for _, idear := range bundled {
+		rec := NewIdeaRecord(idear)
+		rec.Content = nil
+		rec.Path = path.Join(bundleIdeasDir, idear.Filename)
+		manifest.Ideas = append(manifest.Ideas, rec)
+		inBundle[idear.Id] = true
+	}
+	for _, rel := range idea.GetAllRelations() {
+		if inBundle[rel.From] && inBundle[rel.To] {
+			manifest.Relations = append(manifest.Relations, BundleRelation{rel.From, rel.Type, rel.To})
+		}
+	}
+	manifestBz, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return bundled, err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err = writeTarFile(tw, bundleManifestName, manifestBz)
+	if err != nil {
+		return bundled, err
+	}
+	for _, idear := range bundled {
+		bz, err := ioutil.ReadFile(idear.Path())
+		if err != nil {
+			return bundled, err
+		}
+		err = writeTarFile(tw, path.Join(bundleIdeasDir, idear.Filename), bz)
+		if err != nil {
+			return bundled, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return bundled, err
+	}
+	return bundled, gw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, bz []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(bz)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(bz)
+	return err
+}
+
+// ImportBundle recreates the ideas of a bundle within this ranch. Every idea
+// is given a fresh id and all consumes ids, [[id]] links and relations between
+// the ideas of the bundle are rewritten to the new ids. Links to ideas outside
+// of the bundle are left as the plain id.
+func ImportBundle(r io.Reader) (imported idea.Ideas, err error) {
+	tmpDir, err := ioutil.TempDir("", "thranch-bundle")
+	if err != nil {
+		return imported, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return imported, err
+	}
+	tr := tar.NewReader(gr)
+	var manifestBz []byte
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return imported, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		bz, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return imported, err
+		}
+		switch {
+		case hdr.Name == bundleManifestName:
+			manifestBz = bz
+		case path.Dir(hdr.Name) == bundleIdeasDir:
+			// only the base name is used so nothing is written outside of tmpDir
+			err = os.MkdirAll(path.Join(tmpDir, bundleIdeasDir), os.ModePerm)
+			if err != nil {
+				return imported, err
+			}
+			err = ioutil.WriteFile(path.Join(tmpDir, bundleIdeasDir, path.Base(hdr.Name)), bz, 0644)
+			if err != nil {
+				return imported, err
+			}
+		}
+	}
+	if manifestBz == nil {
+		return imported, errors.New("not a bundle, no manifest found")
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestBz, &manifest); err != nil {
+		return imported, err
+	}
+	if manifest.Format != bundleFormat || manifest.Version > bundleVersion {
+		return imported, fmt.Errorf("unsupported bundle format %v version %v",
+			manifest.Format, manifest.Version)
+	}
+
+	// text content is read in so its links can be rewritten
+	inBundle := make(map[uint32]bool)
+	for _, rec := range manifest.Ideas {
+		inBundle[rec.Id] = true
+	}
+	recs := make([]IdeaRecord, len(manifest.Ideas))
+	for i, rec := range manifest.Ideas {
+		rec.Path = path.Join(bundleIdeasDir, path.Base(rec.Path))
+		if kind, err := idea.GetKind(rec.Ext); err == nil && kind == KindText {
+			bz, err := ioutil.ReadFile(path.Join(tmpDir, rec.Path))
+			if err != nil {
+				return imported, fmt.Errorf("bundle missing content of %v", idea.IdStr(rec.Id))
+			}
+			content := string(bz)
+			rec.Content = &content
+		}
+		recs[i] = rec
+	}
+
+	imported, err = ImportJSON(recs, tmpDir, false)
+	if err != nil {
+		return imported, err
+	}
+
+	// the ids of the imported ideas are in the same order as the records
+	remap := make(IdRemap)
+	for i, rec := range recs {
+		remap[rec.Id] = imported[i].Id
+	}
+	rels := idea.GetAllRelations()
+	for _, br := range manifest.Relations {
+		rel, err := idea.NewRelation(remap.Id(br.From), br.Type, remap.Id(br.To))
+		if err != nil || !inBundle[br.From] || !inBundle[br.To] || rels.Has(rel) {
+			continue
+		}
+		rels = append(rels, rel)
+	}
+	idea.WriteRelations(rels)
+	return imported, nil
+}
@@ -0,0 +1,207 @@
+package quac
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// kinds of kindle clippings
+const (
+	ClippingHighlight = "highlight"
+	ClippingNote      = "note"
+	ClippingBookmark  = "bookmark"
+
+	clippingSeparator = "=========="
+)
+
+// layouts of the "Added on" date which vary by kindle locale and firmware
+var clippingDateLayouts = []string{
+	"Monday, January 2, 2006 3:04:05 PM",
+	"Monday, January 2, 2006, 3:04 PM",
+	"Monday, 2 January 2006 15:04:05",
+	"Monday, 2 January 06 15:04:05",
+}
+
+// Clipping is a single highlight, note or bookmark from a kindle
+// "My Clippings.txt" file
+type Clipping struct {
+	Book     string
+	Author   string
+	Kind     string
+	Page     string
+	Location string
+	Added    time.Time
+	Text     string
+}
+
+// ParseClippings reads all the clippings from a kindle "My Clippings.txt" file
+func ParseClippings(r io.Reader) (clips []Clipping, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "\ufeff")
+		if strings.TrimSpace(line) != clippingSeparator {
+			lines = append(lines, line)
+			continue
+		}
+		clip, ok, err := parseClipping(lines)
+		if err != nil {
+			return clips, err
+		}
+		if ok {
+			clips = append(clips, clip)
+		}
+		lines = []string{}
+	}
+	return clips, scanner.Err()
+}
+
+// parse the lines of a single clipping, the first line is the title and
+// author, the second is the metadata, followed by a blank line and the text
+func parseClipping(lines []string) (clip Clipping, ok bool, err error) {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return clip, false, nil
+	}
+	if len(lines) < 2 {
+		return clip, false, fmt.Errorf("bad clipping: %v", lines[0])
+	}
+
+	clip.Book = strings.TrimSpace(lines[0])
+	if strings.HasSuffix(clip.Book, ")") {
+		if i := strings.LastIndex(clip.Book, "("); i > 0 {
+			clip.Author = strings.TrimSpace(clip.Book[i+1 : len(clip.Book)-1])
+			clip.Book = strings.TrimSpace(clip.Book[:i])
+		}
+	}
+
+	meta := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[1]), "-"))
+	for i, part := range strings.Split(meta, "|") {
+		part = strings.TrimSpace(part)
+		lower := strings.ToLower(part)
+		if i == 0 {
+			switch {
+			case strings.Contains(lower, "highlight"):
+				clip.Kind = ClippingHighlight
+			case strings.Contains(lower, "note"):
+				clip.Kind = ClippingNote
+			case strings.Contains(lower, "bookmark"):
+				clip.Kind = ClippingBookmark
+			default:
+				return clip, false, fmt.Errorf("unknown clipping type: %v", lines[1])
+			}
+		}
+		switch {
+		case strings.HasPrefix(lower, "added on "):
+			added := strings.TrimSpace(part[len("added on "):])
+			for _, layout := range clippingDateLayouts {
+				clip.Added, err = time.Parse(layout, added)
+				if err == nil {
+					break
+				}
+			}
+			if err != nil {
+				return clip, false, fmt.Errorf("bad clipping date: %v", added)
+			}
+		case strings.Contains(lower, "location "):
+			clip.Location = lastField(part)
+		case strings.Contains(lower, "page "):
+			clip.Page = lastField(part)
+		}
+	}
+
+	clip.Text = strings.TrimSpace(strings.Join(lines[2:], "\n"))
+	return clip, true, nil
+}
+
+func lastField(in string) string {
+	fields := strings.Fields(in)
+	return fields[len(fields)-1]
+}
+
+// hash identifying the clipping, used to not import the same clipping twice
+func (clip Clipping) Hash() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{clip.Book, clip.Author,
+		clip.Kind, clip.Page, clip.Location, clip.Text}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (clip Clipping) tags() []idea.Tag {
+	// dots (such as of initials) would be read as the extension of the filename
+	noDots := strings.NewReplacer(".", "-")
+	tagStrs := []string{"kindle-" + clip.Kind, "book=" + noDots.Replace(clip.Book)}
+	if clip.Author != "" {
+		tagStrs = append(tagStrs, "author="+noDots.Replace(clip.Author))
+	}
+	if clip.Location != "" {
+		tagStrs = append(tagStrs, "loc="+clip.Location)
+	} else if clip.Page != "" {
+		tagStrs = append(tagStrs, "page="+clip.Page)
+	}
+	return importTags(tagStrs)
+}
+
+// ImportClippings creates a text idea for each highlight and note, clippings
+// which have previously been imported (as recorded in the clippings ledger)
+// are skipped as are bookmarks which have no text.
+func ImportClippings(clips []Clipping) (imported idea.Ideas, skipped int, err error) {
+	ledger, err := cmn.ReadLines(ClippingsLedger)
+	if err != nil {
+		return imported, skipped, err
+	}
+	seen := make(map[string]bool)
+	for _, hash := range ledger {
+		seen[hash] = true
+	}
+
+	ledgerFile, err := os.OpenFile(ClippingsLedger, os.O_APPEND|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return imported, skipped, err
+	}
+	defer ledgerFile.Close()
+
+	for _, clip := range clips {
+		hash := clip.Hash()
+		if seen[hash] || clip.Kind == ClippingBookmark || clip.Text == "" {
+			skipped++
+			continue
+		}
+		created := idea.TodayDate()
+		if !clip.Added.IsZero() {
+			created = truncateToDate(clip.Added)
+		}
+		idear := idea.Idea{
+			Cycle:       CycleAlive,
+			Id:          idea.GetNextID(),
+			ConsumesIds: []uint32{},
+			Kind:        KindText,
+			Created:     created,
+			Edited:      created,
+			Tags:        clip.tags(),
+		}
+		(&idear).UpdateFilename()
+		WriteIdea(idear.Filename, clip.Text+"\n")
+		idea.IncrementID()
+
+		// record as imported straight away so an interrupted import may be rerun
+		seen[hash] = true
+		if _, err = ledgerFile.WriteString(hash + "\n"); err != nil {
+			return imported, skipped, err
+		}
+		imported = append(imported, idear)
+	}
+	return imported, skipped, nil
+}
@@ -0,0 +1,63 @@
+package quac
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testClippings = "\ufeffThe Mind's I (Hofstadter, Douglas R.)\r\n" +
+	"- Your Highlight on page 12 | Location 170-172 | Added on Saturday, March 7, 2020 9:15:02 PM\r\n" +
+	"\r\n" +
+	"The self is a strange loop.\r\n" +
+	"==========\r\n" +
+	"The Mind's I (Hofstadter, Douglas R.)\r\n" +
+	"- Your Note on Location 172 | Added on Saturday, March 7, 2020 9:16:40 PM\r\n" +
+	"\r\n" +
+	"compare with GEB\r\n" +
+	"second line\r\n" +
+	"==========\r\n" +
+	"Untitled Document\r\n" +
+	"- Your Bookmark on Location 5 | Added on Sunday, 8 March 2020 10:00:00\r\n" +
+	"\r\n" +
+	"\r\n" +
+	"==========\r\n"
+
+func TestParseClippings(t *testing.T) {
+	clips, err := ParseClippings(strings.NewReader(testClippings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 3 {
+		t.Fatalf("expected 3 clippings, got %v", len(clips))
+	}
+
+	hl := clips[0]
+	if hl.Book != "The Mind's I" || hl.Author != "Hofstadter, Douglas R." {
+		t.Errorf("bad book or author: %q %q", hl.Book, hl.Author)
+	}
+	if hl.Kind != ClippingHighlight || hl.Page != "12" || hl.Location != "170-172" {
+		t.Errorf("bad metadata: %+v", hl)
+	}
+	if !hl.Added.Equal(time.Date(2020, 3, 7, 21, 15, 2, 0, time.UTC)) {
+		t.Errorf("bad added date: %v", hl.Added)
+	}
+	if hl.Text != "The self is a strange loop." {
+		t.Errorf("bad text: %q", hl.Text)
+	}
+
+	note := clips[1]
+	if note.Kind != ClippingNote || note.Location != "172" || note.Text != "compare with GEB\nsecond line" {
+		t.Errorf("bad note: %+v", note)
+	}
+
+	bm := clips[2]
+	if bm.Kind != ClippingBookmark || bm.Author != "" || bm.Text != "" ||
+		!bm.Added.Equal(time.Date(2020, 3, 8, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("bad bookmark: %+v", bm)
+	}
+
+	if hl.Hash() == note.Hash() {
+		t.Error("different clippings should have different hashes")
+	}
+}
@@ -6,9 +6,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"path"
-	"strconv"
 )
 
 // open supported files
@@ -26,7 +24,7 @@ func Open(pathToOpen string) {
 	case KindText:
 		OpenText(pathToOpen)
 	case KindEnText:
-		OpenText(pathToOpen)
+		OpenEncrypted(pathToOpen)
 	case KindImage:
 		ViewImage(pathToOpen)
 	case KindAudio:
@@ -48,6 +46,8 @@ func View(pathToOpen string) {
 	switch kind {
 	case KindText:
 		ViewText(pathToOpen)
+	case KindEnText:
+		ViewEncrypted(pathToOpen)
 	case KindImage:
 		ViewImage(pathToOpen)
 	case KindAudio:
@@ -61,11 +61,7 @@ func ViewImage(pathToOpen string) {
 }
 
 func ViewImageNoFilename(pathToOpen string) {
-	//fmt.Printf("debug pathToOpen: %v\n", pathToOpen)
-	cmd := exec.Command("kitty", "+kitten", "icat", pathToOpen) // using kitty command line
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	err := RenderImage(os.Stdout, pathToOpen)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -74,10 +70,7 @@ func ViewImageNoFilename(pathToOpen string) {
 func ListenAudio(pathToOpen string) {
 
 	fmt.Println(path.Base(pathToOpen))
-	cmd := exec.Command("afplay", pathToOpen)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	err := PlayAudio(pathToOpen)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -92,14 +85,16 @@ func ViewText(filepath string) {
 }
 
 func OpenText(pathToOpen string) {
+	openTextWith(GetEditor(), pathToOpen)
+}
+
+// open the text with the editor, updating the edited date if changed
+func openTextWith(editor Editor, pathToOpen string) {
 
 	// ignore error, allow for no file to be present
 	origBz, _ := ioutil.ReadFile(pathToOpen)
 
-	cmd := exec.Command("vim", "-c", "+normal 1G1|", pathToOpen) //start in the upper left corner nomatter
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	err := editor.Open(pathToOpen, 1)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -113,25 +108,21 @@ func OpenText(pathToOpen string) {
 	}
 }
 
-func SetEncryptionById(id uint32) {
-
-	pathToOpen, found := GetFilepathByID(id)
-	if !found {
-		fmt.Println("nothing found at that ID")
-		os.Exit(1)
-	}
-	enPath := UpdateFilepathToEncrypted(pathToOpen)
+// open a text file for editing without updating any idea information
+func EditText(pathToOpen string) {
+	EditTextAtLine(pathToOpen, 1)
+}
 
-	// ignore error, allow for no file to be present
-	cmd := exec.Command("vim", "-c", "X", enPath) //start in the upper left corner nomatter
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+// open a text file for editing with the cursor at the line
+func EditTextAtLine(pathToOpen string, line int) {
+	err := GetEditor().Open(pathToOpen, line)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
+// open the working filenames and content side by side, editors which cannot
+// bind the scrolling of a split instead edit a single file working view
 func OpenTextSplit(pathToOpenLeft, pathToOpenRight string, maxFNLen int) {
 
 	// limit the split
@@ -139,15 +130,12 @@ func OpenTextSplit(pathToOpenLeft, pathToOpenRight string, maxFNLen int) {
 		maxFNLen = 65
 	}
 
-	cmd := exec.Command("vim",
-		"-c", "vertical resize "+strconv.Itoa(maxFNLen+4)+
-			" | set scb!"+ // set the scrollbind
-			" | execute \"normal \\<C-w>\\<C-l>\""+
-			" | set scb!", "-O", pathToOpenLeft, pathToOpenRight)
-
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	editor, ok := GetEditor().(SplitEditor)
+	if !ok {
+		OpenWorkingView(pathToOpenLeft, pathToOpenRight)
+		return
+	}
+	err := editor.OpenSplit(pathToOpenLeft, pathToOpenRight, maxFNLen+4)
 	if err != nil {
 		log.Fatal(err)
 	}
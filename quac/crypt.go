@@ -0,0 +1,202 @@
+package quac
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+	"golang.org/x/term"
+)
+
+// passphrase entered during this process, only prompted for once
+var sessionPassphrase []byte
+
+func init() {
+	idea.Passphrase = GetPassphrase
+}
+
+// GetPassphrase prompts for the passphrase of encrypted ideas once per
+// process. If confirm is true the passphrase must be entered twice.
+func GetPassphrase(confirm bool) ([]byte, error) {
+	if sessionPassphrase != nil {
+		return sessionPassphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("a terminal is required to enter the passphrase")
+	}
+	fmt.Fprint(os.Stderr, "passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "confirm passphrase: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New("passphrases do not match")
+		}
+	}
+	sessionPassphrase = passphrase
+	return passphrase, nil
+}
+
+// EncryptFile encrypts the file in place with the passphrase of this session
+func EncryptFile(filepath string) error {
+	plaintext, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+	if IsNativeEncrypted(plaintext) || IsLegacyEncrypted(plaintext) {
+		return errors.New("already encrypted: " + filepath)
+	}
+	passphrase, err := GetPassphrase(true)
+	if err != nil {
+		return err
+	}
+	bz, err := Encrypt(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath, bz, 0600)
+}
+
+// SetEncryptionById encrypts a text idea, the idea is renamed with the .en
+// extension
+func SetEncryptionById(id uint32) {
+	pathToOpen, found := GetFilepathByID(id)
+	if !found {
+		fmt.Println("nothing found at that ID")
+		os.Exit(1)
+	}
+	kind, err := GetKind(path.Ext(pathToOpen))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if kind != KindText {
+		log.Fatalf("only text ideas can be encrypted: %v", path.Base(pathToOpen))
+	}
+	if err := EncryptFile(pathToOpen); err != nil {
+		log.Fatal(err)
+	}
+	enPath := UpdateFilepathToEncrypted(pathToOpen)
+	fmt.Printf("encrypted: %v\n", path.Base(enPath))
+}
+
+// ViewEncrypted prints the decrypted content of an encrypted idea
+func ViewEncrypted(pathToOpen string) {
+	bz, err := ioutil.ReadFile(pathToOpen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if IsLegacyEncrypted(bz) {
+		cmd := exec.Command("vim", "-R", pathToOpen)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		if err := cmd.Run(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	passphrase, err := GetPassphrase(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plaintext, err := Decrypt(bz, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n", plaintext)
+}
+
+// OpenEncrypted edits an encrypted idea. The content is decrypted into a
+// temporary file only readable by the user (in memory where available) which
+// is re-encrypted if changed and then wiped. Legacy vim encrypted ideas are
+// opened directly with vim.
+func OpenEncrypted(pathToOpen string) {
+	bz, err := ioutil.ReadFile(pathToOpen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if IsLegacyEncrypted(bz) {
+		openTextWith(vimEditor{"vim", nil}, pathToOpen)
+		return
+	}
+	passphrase, err := GetPassphrase(false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plaintext, err := Decrypt(bz, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	edited, err := editInTempFile(plaintext)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if bytes.Equal(edited, plaintext) {
+		return
+	}
+	encrypted, err := Encrypt(edited, passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pathToOpen, encrypted, 0600); err != nil {
+		log.Fatal(err)
+	}
+	UpdateEditedDateNow(pathToOpen)
+}
+
+// edit the content within a temporary file which is wiped afterwards
+func editInTempFile(content []byte) (edited []byte, err error) {
+	tmpDir := ""
+	if dirExists("/dev/shm") {
+		tmpDir = "/dev/shm"
+	}
+	editor := GetEditor()
+	tmp, err := ioutil.TempFile(tmpDir, "thranch-en-")
+	if err != nil {
+		return nil, err
+	}
+	defer wipeFile(tmp.Name())
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// errors are returned (rather than fatal) so the plaintext is always wiped
+	if err := editor.Open(tmp.Name(), 1); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(tmp.Name())
+}
+
+func dirExists(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir()
+}
+
+// overwrite the file with zeros before removing it
+func wipeFile(filepath string) {
+	if fi, err := os.Stat(filepath); err == nil {
+		_ = ioutil.WriteFile(filepath, make([]byte, fi.Size()), 0600)
+	}
+	_ = os.Remove(filepath)
+}
@@ -1,26 +1,83 @@
 package quac
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path"
+	"strings"
 
+	cmn "github.com/rigelrozanski/common"
 	"github.com/rigelrozanski/thranch/quac/idea"
 )
 
-// Display the immediate lineage of ideas
+// Display the full consumption lineage of an idea as an ancestor tree
+// followed by a descendant tree
 func GetLineage(id uint32) (compiled string) {
-	lineageIdea := GetIdeaByID(id, false)
-	for _, consume := range lineageIdea.ConsumesIds {
-		fn := GetFilenameByID(consume)
-		content, found := GetContentByID(consume)
+	lin := idea.NewLineage(GetAllIdeas())
+	root, found := lin.Get(id)
+	if !found {
+		log.Fatalf("idea not found: %v", id)
+	}
+
+	compiled = "ANCESTORS\n" + lineageLabel(lin, root) + "\n"
+	compiled += lineageTree(lin, id, "", lin.Parents, map[uint32]bool{id: true})
+	compiled += "\nDESCENDANTS\n" + lineageLabel(lin, root) + "\n"
+	compiled += lineageTree(lin, id, "", lin.Children, map[uint32]bool{id: true})
+	return compiled
+}
+
+// recursively render the branches of the lineage tree below the id
+func lineageTree(lin idea.Lineage, id uint32, indent string,
+	branches func(uint32) []uint32, visited map[uint32]bool) (out string) {
+
+	ids := branches(id)
+	for i, bid := range ids {
+		joint, nextIndent := "|-- ", indent+"|   "
+		if i == len(ids)-1 {
+			joint, nextIndent = "`-- ", indent+"    "
+		}
+
+		bidea, found := lin.Get(bid)
 		if !found {
-			log.Fatalf("child not found: %v", consume)
+			out += fmt.Sprintf("%v%v%v (missing)\n", indent, joint, idea.IdStr(bid))
+			continue
+		}
+		if visited[bid] { // protect against circular consumption
+			out += fmt.Sprintf("%v%v%v (cycle)\n", indent, joint, idea.IdStr(bid))
+			continue
 		}
-		compiled = fmt.Sprintf("%v\n%v\n%s", compiled, fn, content)
+		out += indent + joint + lineageLabel(lin, bidea) + "\n"
+
+		visited[bid] = true
+		out += lineageTree(lin, bid, nextIndent, branches, visited)
+		delete(visited, bid)
 	}
-	return compiled
+	return out
+}
+
+// single line description of an idea within the lineage tree
+func lineageLabel(lin idea.Lineage, idear idea.Idea) string {
+	label := fmt.Sprintf("%v %-9v %v e%v",
+		idea.IdStr(idear.Id), idea.KindName(idear.Kind),
+		idear.Created.Format(cmn.LayoutYYYYdMMdDD),
+		idear.Edited.Format(cmn.LayoutYYYYdMMdDD))
+	if idear.Cycle != CycleAlive {
+		label += " c" + idear.Consumed.Format(cmn.LayoutYYYYdMMdDD)
+	}
+	label += " " + idear.GetClumpedTags()
+
+	// show what a transcription was transcribed from
+	if idear.IsText() {
+		for _, pid := range lin.Parents(idear.Id) {
+			parent, found := lin.Get(pid)
+			if found && (parent.IsImage() || parent.IsAudio()) {
+				label += " <- " + parent.Filename
+			}
+		}
+	}
+	return label
 }
 
 // copy an idea by the id
@@ -36,6 +93,22 @@ func SetConsume(consumedId uint32, entry string) (consumerFilepath string) {
 	return consumerIdea.Path()
 }
 
+// record an audio idea which consumes the idea (an audio transcription)
+func SetConsumeRecording(consumedId uint32) (consumerFilepath string, err error) {
+	consumedIdea := GetIdeaByID(consumedId, true)
+
+	consumerIdea := idea.NewConsumingTextIdea(consumedIdea)
+	consumerIdea.Kind, consumerIdea.Ext = idea.KindAudio, ".wav"
+	(&consumerIdea).UpdateFilename()
+	idea.IncrementID()
+	if err := RecordAudio(consumerIdea.Path()); err != nil {
+		return "", unusedRecordingIdErr(err, consumerIdea.Id)
+	}
+
+	consumedIdea.SetConsumed()
+	return consumerIdea.Path(), nil
+}
+
 func SetConsumes(consumedId, consumesId uint32) {
 
 	consumedIdea := GetIdeaByID(consumedId, true)
@@ -54,6 +127,75 @@ func SetConsumes(consumedId, consumesId uint32) {
 	consumedIdea.SetConsumed()
 }
 
+// separator placed between the contents of merged ideas
+const MergeSeparator = "\n_______________________________\n\n"
+
+// merge the contents of the ideas (in the order provided) into a new idea
+// which consumes all of them
+func SetMerge(idears idea.Ideas) (consumerFilepath string, err error) {
+	if len(idears) < 2 {
+		return "", errors.New("at least two ideas are required to merge")
+	}
+	var contents []string
+	for _, idear := range idears {
+		if idear.Cycle != CycleAlive {
+			return "", fmt.Errorf("cannot merge non-alive idea %v", idear.Filename)
+		}
+		if !idear.IsText() {
+			return "", fmt.Errorf("cannot merge non-text idea %v", idear.Filename)
+		}
+		contents = append(contents, strings.TrimRight(string(idear.GetContent()), "\n"))
+	}
+
+	consumerIdea := idea.NewMergingTextIdea(idears)
+	idea.IncrementID()
+	WriteIdea(consumerIdea.Filename, strings.Join(contents, MergeSeparator)+"\n")
+
+	err = SetConsumedAll(idears)
+	if err != nil {
+		_ = os.Remove(consumerIdea.Path())
+		return "", err
+	}
+	return consumerIdea.Path(), nil
+}
+
+// mark all the ideas as consumed, if any of the ideas cannot be consumed then
+// none of them are
+func SetConsumedAll(idears idea.Ideas) error {
+	var srcPaths, writePaths []string
+	for _, idear := range idears {
+		srcPaths = append(srcPaths, idear.Path())
+		idear.Cycle = CycleConsumed
+		idear.Consumed = idea.TodayDate()
+		(&idear).UpdateFilename()
+		writePaths = append(writePaths, idear.Path())
+	}
+	return RenameAll(srcPaths, writePaths)
+}
+
+// perform all the renames or, upon any failure, revert those already
+// performed and return the error
+func RenameAll(srcPaths, writePaths []string) error {
+	for _, writePath := range writePaths {
+		if cmn.FileExists(writePath) {
+			return fmt.Errorf("file already exists: %v", writePath)
+		}
+	}
+	for i := range srcPaths {
+		err := os.Rename(srcPaths[i], writePaths[i])
+		if err == nil {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if rerr := os.Rename(writePaths[j], srcPaths[j]); rerr != nil {
+				log.Printf("could not revert %v: %v", writePaths[j], rerr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
 // Set a consumed idea to zombie
 func SetZombie(zombieId uint32) {
 	consumedIdea := GetIdeaByID(zombieId, true)
@@ -0,0 +1,178 @@
+package quac
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Editor opens text files for editing, the editor is chosen by editor= within
+// the thranch config, otherwise by $VISUAL or $EDITOR, otherwise vim.
+type Editor interface {
+	// open the file for editing with the cursor at the line (starting at 1)
+	Open(filepath string, line int) error
+}
+
+// SplitEditor is an editor which can open two files side by side with their
+// scrolling bound together, the left pane being leftWidth columns wide
+type SplitEditor interface {
+	Editor
+	OpenSplit(left, right string, leftWidth int) error
+}
+
+// editor command from the thranch config
+var EditorCmd string
+
+// GetEditor returns the adapter for the configured editor. The editor command
+// may include arguments (such as "code --wait").
+func GetEditor() Editor {
+	cmdStr := EditorCmd
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if cmdStr == "" {
+			cmdStr = os.Getenv(env)
+		}
+	}
+	fields := strings.Fields(cmdStr)
+	if len(fields) == 0 {
+		return vimEditor{"vim", nil}
+	}
+	bin, args := fields[0], fields[1:]
+	switch path.Base(bin) {
+	case "vim", "nvim", "vi":
+		return vimEditor{bin, args}
+	case "gvim", "mvim":
+		// the gui forks and returns at once unless kept in the foreground
+		for _, arg := range args {
+			if arg == "-f" || arg == "--nofork" {
+				return vimEditor{bin, args}
+			}
+		}
+		return vimEditor{bin, withArgs([]string{"-f"}, args...)}
+	case "hx", "helix":
+		return helixEditor{bin, args}
+	case "emacs":
+		return emacsEditor{bin, args}
+	case "emacsclient", "nano", "micro", "kak", "joe":
+		return lineEditor{bin, args}
+	case "code", "codium", "code-insiders":
+		return codeEditor{bin, args}
+	}
+	return genericEditor{bin, args}
+}
+
+func runEditor(bin string, args ...string) error {
+	cmd := exec.Command(bin, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func withArgs(args []string, more ...string) []string {
+	return append(append([]string{}, args...), more...)
+}
+
+// ------------------------------------------
+// vim and nvim
+type vimEditor struct {
+	bin  string
+	args []string
+}
+
+var _ SplitEditor = vimEditor{}
+
+func (e vimEditor) Open(filepath string, line int) error {
+	// start at the beginning of the line no matter the last cursor position
+	return runEditor(e.bin, withArgs(e.args, "-c", "+normal "+strconv.Itoa(line)+"G1|", filepath)...)
+}
+
+func (e vimEditor) OpenSplit(left, right string, leftWidth int) error {
+	return runEditor(e.bin, withArgs(e.args,
+		"-c", "vertical resize "+strconv.Itoa(leftWidth)+
+			" | set scb!"+ // set the scrollbind
+			" | execute \"normal \\<C-w>\\<C-l>\""+
+			" | set scb!", "-O", left, right)...)
+}
+
+// ------------------------------------------
+// helix, which has no scroll binding between splits
+type helixEditor struct {
+	bin  string
+	args []string
+}
+
+var _ Editor = helixEditor{}
+
+func (e helixEditor) Open(filepath string, line int) error {
+	return runEditor(e.bin, withArgs(e.args, fmt.Sprintf("%v:%v", filepath, line))...)
+}
+
+// ------------------------------------------
+// emacs, splits are bound with scroll-all-mode
+type emacsEditor struct {
+	bin  string
+	args []string
+}
+
+var _ SplitEditor = emacsEditor{}
+
+func (e emacsEditor) Open(filepath string, line int) error {
+	return runEditor(e.bin, withArgs(e.args, "+"+strconv.Itoa(line), filepath)...)
+}
+
+func (e emacsEditor) OpenSplit(left, right string, leftWidth int) error {
+	eval := fmt.Sprintf(`(progn (find-file %q) (split-window-right %v) `+
+		`(other-window 1) (find-file %q) (scroll-all-mode 1))`, left, leftWidth, right)
+	return runEditor(e.bin, withArgs(e.args, "--eval", eval)...)
+}
+
+// ------------------------------------------
+// vs code (and codium), which must wait for the file to be closed
+type codeEditor struct {
+	bin  string
+	args []string
+}
+
+var _ Editor = codeEditor{}
+
+func (e codeEditor) waitArgs() []string {
+	for _, arg := range e.args {
+		if arg == "--wait" || arg == "-w" {
+			return e.args
+		}
+	}
+	return withArgs(e.args, "--wait")
+}
+
+func (e codeEditor) Open(filepath string, line int) error {
+	return runEditor(e.bin, withArgs(e.waitArgs(), "--goto", fmt.Sprintf("%v:%v", filepath, line))...)
+}
+
+// ------------------------------------------
+// editors which jump to a line with a +line argument
+type lineEditor struct {
+	bin  string
+	args []string
+}
+
+var _ Editor = lineEditor{}
+
+func (e lineEditor) Open(filepath string, line int) error {
+	return runEditor(e.bin, withArgs(e.args, "+"+strconv.Itoa(line), filepath)...)
+}
+
+// ------------------------------------------
+// any other editor, only the file is provided
+type genericEditor struct {
+	bin  string
+	args []string
+}
+
+var _ Editor = genericEditor{}
+
+func (e genericEditor) Open(filepath string, line int) error {
+	return runEditor(e.bin, withArgs(e.args, filepath)...)
+}
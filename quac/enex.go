@@ -0,0 +1,279 @@
+package quac
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// layout of the dates within an evernote export
+const enexDateLayout = "20060102T150405Z"
+
+// a note within an evernote export (.enex)
+type enexNote struct {
+	Title     string         `xml:"title"`
+	Content   string         `xml:"content"`
+	Created   string         `xml:"created"`
+	Updated   string         `xml:"updated"`
+	Tags      []string       `xml:"tag"`
+	Resources []enexResource `xml:"resource"`
+}
+
+type enexResource struct {
+	Data     string `xml:"data"`
+	Mime     string `xml:"mime"`
+	Filename string `xml:"resource-attributes>file-name"`
+}
+
+// ImportENEX creates a text idea for every note within an evernote export.
+// Evernote tags become tags and the created and updated times are kept.
+// Image and audio resources become ideas which are consumed by the text idea
+// of the note, and are linked from where they were embedded within the note.
+func ImportENEX(r io.Reader) (imported idea.Ideas, err error) {
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return imported, nil
+		} else if err != nil {
+			return imported, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		var note enexNote
+		if err := dec.DecodeElement(&note, &start); err != nil {
+			return imported, err
+		}
+		noteIdeas, err := importENEXNote(note)
+		if err != nil {
+			return imported, fmt.Errorf("note %q: %v", note.Title, err)
+		}
+		imported = append(imported, noteIdeas...)
+	}
+}
+
+func parseENEXDate(in string) (time.Time, bool) {
+	t, err := time.Parse(enexDateLayout, strings.TrimSpace(in))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return truncateToDate(t.Local()), true
+}
+
+func importENEXNote(note enexNote) (imported idea.Ideas, err error) {
+	today := idea.TodayDate()
+	created, ok := parseENEXDate(note.Created)
+	if !ok {
+		created = today
+	}
+	edited, ok := parseENEXDate(note.Updated)
+	if !ok {
+		edited = created
+	}
+	tags := importTags(note.Tags)
+
+	// resources are written first so the note can consume and link them,
+	// they are keyed by the md5 hash used within the <en-media> element
+	resources := make(map[string]string)
+	var consumesIds []uint32
+	for _, res := range note.Resources {
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(res.Data), ""))
+		if err != nil {
+			return imported, fmt.Errorf("bad resource %v: %v", res.Filename, err)
+		}
+		sum := md5.Sum(data)
+		hash := hex.EncodeToString(sum[:])
+
+		ext, found := MimeExtension(res.Mime)
+		if !found {
+			resources[hash] = fmt.Sprintf("[attachment not imported: %v (%v)]", res.Filename, res.Mime)
+			continue
+		}
+		resIdea, err := importFile(data, ext, CycleConsumed, created, edited, tags)
+		if err != nil {
+			return imported, err
+		}
+		resources[hash] = "[[" + idea.IdStr(resIdea.Id) + "]]"
+		consumesIds = append(consumesIds, resIdea.Id)
+		imported = append(imported, resIdea)
+	}
+
+	text := HTMLToText(note.Content, func(hash string) string {
+		return resources[strings.ToLower(hash)]
+	})
+	if strings.TrimSpace(note.Title) != "" {
+		text = "# " + strings.TrimSpace(note.Title) + "\n\n" + text
+	}
+
+	noteIdea := idea.Idea{
+		Cycle:       CycleAlive,
+		Id:          idea.GetNextID(),
+		ConsumesIds: consumesIds,
+		Kind:        KindText,
+		Created:     created,
+		Edited:      edited,
+		Tags:        tags,
+	}
+	(&noteIdea).UpdateFilename()
+	WriteIdea(noteIdea.Filename, text)
+	idea.IncrementID()
+	return append(imported, noteIdea), nil
+}
+
+var rxManyNewlines = regexp.MustCompile(`\n{3,}`)
+
+// HTMLToText converts html, such as the ENML content of an evernote note, into
+// markdown flavoured plain text. The media function provides the text which
+// replaces each embedded <en-media> element given the hash of the resource.
+func HTMLToText(enml string, media func(hash string) string) string {
+	dec := xml.NewDecoder(strings.NewReader(enml))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+
+	var sb strings.Builder
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	attr := func(el xml.StartElement, name string) string {
+		for _, a := range el.Attr {
+			if a.Name.Local == name {
+				return a.Value
+			}
+		}
+		return ""
+	}
+
+	var lists []int // item counter of each open list, -1 for unordered lists
+	var hrefs []string
+	pre, hidden := 0, 0
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch el := token.(type) {
+		case xml.StartElement:
+			switch strings.ToLower(el.Name.Local) {
+			case "head", "style", "script", "title":
+				hidden++
+			case "div", "p", "table", "blockquote":
+				newline()
+			case "tr":
+				newline()
+				sb.WriteString("| ")
+			case "br":
+				sb.WriteString("\n")
+			case "hr":
+				newline()
+				sb.WriteString("---\n")
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				newline()
+				sb.WriteString("\n" + strings.Repeat("#", int(el.Name.Local[1]-'0')) + " ")
+			case "ul":
+				newline()
+				lists = append(lists, -1)
+			case "ol":
+				newline()
+				lists = append(lists, 0)
+			case "li":
+				newline()
+				if len(lists) == 0 {
+					sb.WriteString("- ")
+					break
+				}
+				sb.WriteString(strings.Repeat("  ", len(lists)-1))
+				if lists[len(lists)-1] < 0 {
+					sb.WriteString("- ")
+				} else {
+					lists[len(lists)-1]++
+					sb.WriteString(fmt.Sprintf("%v. ", lists[len(lists)-1]))
+				}
+			case "b", "strong":
+				sb.WriteString("**")
+			case "i", "em":
+				sb.WriteString("_")
+			case "pre":
+				newline()
+				sb.WriteString("```\n")
+				pre++
+			case "a":
+				hrefs = append(hrefs, attr(el, "href"))
+				sb.WriteString("[")
+			case "en-todo":
+				if attr(el, "checked") == "true" {
+					sb.WriteString("[x] ")
+				} else {
+					sb.WriteString("[ ] ")
+				}
+			case "en-media":
+				sb.WriteString(media(attr(el, "hash")))
+			}
+		case xml.EndElement:
+			switch strings.ToLower(el.Name.Local) {
+			case "head", "style", "script", "title":
+				hidden--
+			case "div", "p", "table", "blockquote", "li":
+				newline()
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				sb.WriteString("\n\n")
+			case "td", "th":
+				sb.WriteString(" | ")
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				newline()
+			case "b", "strong":
+				sb.WriteString("**")
+			case "i", "em":
+				sb.WriteString("_")
+			case "pre":
+				newline()
+				sb.WriteString("```\n")
+				pre--
+			case "a":
+				href := ""
+				if len(hrefs) > 0 {
+					href, hrefs = hrefs[len(hrefs)-1], hrefs[:len(hrefs)-1]
+				}
+				sb.WriteString("](" + href + ")")
+			}
+		case xml.CharData:
+			if hidden > 0 {
+				break
+			}
+			text := string(el)
+			if pre == 0 {
+				text = strings.Join(strings.Fields(text), " ")
+				soFar := sb.String()
+				if text != "" && unicode.IsSpace(rune(el[0])) && soFar != "" &&
+					!strings.HasSuffix(soFar, "\n") && !strings.HasSuffix(soFar, " ") {
+					text = " " + text
+				}
+				if text != "" && unicode.IsSpace(rune(el[len(el)-1])) {
+					text += " "
+				}
+			}
+			sb.WriteString(text)
+		}
+	}
+
+	out := strings.Replace(sb.String(), " \n", "\n", -1)
+	out = rxManyNewlines.ReplaceAllString(out, "\n\n")
+	return strings.TrimSpace(out) + "\n"
+}
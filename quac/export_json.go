@@ -0,0 +1,268 @@
+package quac
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// IdeaRecord is the structured representation of an idea used for json
+// dumps. Text content is included inline, other content is referenced by path.
+type IdeaRecord struct {
+	Id          uint32      `json:"id"`
+	Filename    string      `json:"filename"`
+	Cycle       string      `json:"cycle"`
+	Kind        string      `json:"kind"`
+	Ext         string      `json:"ext"`
+	Created     string      `json:"created"`
+	Edited      string      `json:"edited"`
+	Consumed    string      `json:"consumed,omitempty"`
+	ConsumesIds []uint32    `json:"consumes_ids"`
+	Tags        []TagRecord `json:"tags"`
+	Content     *string     `json:"content,omitempty"`
+	Path        string      `json:"path,omitempty"`
+}
+
+type TagRecord struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// NewIdeaRecord creates a new IdeaRecord object
+func NewIdeaRecord(idear idea.Idea) IdeaRecord {
+	rec := IdeaRecord{
+		Id:          idear.Id,
+		Filename:    idear.Filename,
+		Cycle:       idea.CycleName(idear.Cycle),
+		Kind:        idea.KindName(idear.Kind),
+		Ext:         idear.Ext,
+		Created:     idear.Created.Format(cmn.LayoutYYYYdMMdDD),
+		Edited:      idear.Edited.Format(cmn.LayoutYYYYdMMdDD),
+		ConsumesIds: idear.ConsumesIds,
+		Tags:        []TagRecord{},
+	}
+	if rec.ConsumesIds == nil {
+		rec.ConsumesIds = []uint32{}
+	}
+	if idear.Cycle != CycleAlive {
+		rec.Consumed = idear.Consumed.Format(cmn.LayoutYYYYdMMdDD)
+	}
+	for _, tag := range idear.Tags {
+		rec.Tags = append(rec.Tags, TagRecord{tag.GetName(), tag.GetValue()})
+	}
+	if idear.IsText() {
+		content := string(idear.GetContent())
+		rec.Content = &content
+	} else {
+		rec.Path = idear.Path()
+	}
+	return rec
+}
+
+// convert the record into an idea (the filename is updated but no file is
+// written)
+func (rec IdeaRecord) ToIdea() (idear idea.Idea, err error) {
+	idear.Id = rec.Id
+	idear.Ext = rec.Ext
+	idear.Kind, err = idea.GetKind(rec.Ext)
+	if err != nil {
+		return idear, err
+	}
+	idear.Cycle, err = idea.ParseCycleName(rec.Cycle)
+	if err != nil {
+		return idear, err
+	}
+	idear.Created, err = cmn.ParseYYYYdMMdDD(rec.Created)
+	if err != nil {
+		return idear, fmt.Errorf("bad created date for %v: %v", rec.Id, err)
+	}
+	idear.Edited, err = cmn.ParseYYYYdMMdDD(rec.Edited)
+	if err != nil {
+		return idear, fmt.Errorf("bad edited date for %v: %v", rec.Id, err)
+	}
+	if idear.Cycle != CycleAlive {
+		idear.Consumed, err = cmn.ParseYYYYdMMdDD(rec.Consumed)
+		if err != nil {
+			return idear, fmt.Errorf("bad consumed date for %v: %v", rec.Id, err)
+		}
+	}
+	idear.ConsumesIds = append([]uint32{}, rec.ConsumesIds...)
+	for _, tr := range rec.Tags {
+		tags, err := idea.NewTagReg(tr.Name, tr.Value)
+		if err != nil {
+			return idear, err
+		}
+		idear.Tags = append(idear.Tags, tags...)
+	}
+	if len(idear.Tags) == 0 {
+		return idear, fmt.Errorf("no tags on record %v", rec.Id)
+	}
+	(&idear).UpdateFilename()
+	return idear, nil
+}
+
+// record of an idea for export, encrypted ideas have their decrypted content
+// inline when IncludeEncrypted is set
+func exportIdeaRecord(idear idea.Idea) (IdeaRecord, error) {
+	rec := NewIdeaRecord(idear)
+	if idear.Kind != KindEnText || !idea.IncludeEncrypted {
+		return rec, nil
+	}
+	bz, err := idear.GetPlainContent()
+	if err != nil {
+		return rec, err
+	}
+	content := string(bz)
+	rec.Content = &content
+	rec.Path = ""
+	return rec, nil
+}
+
+// write one record per idea either as a json array or as newline delimited
+// json (one record per line)
+func ExportJSON(idears idea.Ideas, w io.Writer, ndjson bool) error {
+	enc := json.NewEncoder(w)
+	if ndjson {
+		for _, idear := range idears {
+			rec, err := exportIdeaRecord(idear)
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	recs := []IdeaRecord{}
+	for _, idear := range idears {
+		rec, err := exportIdeaRecord(idear)
+		if err != nil {
+			return err
+		}
+		recs = append(recs, rec)
+	}
+	enc.SetIndent("", "  ")
+	return enc.Encode(recs)
+}
+
+// read records from either a json array or newline delimited json
+func ReadIdeaRecords(r io.Reader) (recs []IdeaRecord, err error) {
+	br := bufio.NewReader(r)
+	for {
+		ch, _, err := br.ReadRune()
+		if err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		if strings.TrimSpace(string(ch)) == "" {
+			continue
+		}
+		if err := br.UnreadRune(); err != nil {
+			return recs, err
+		}
+		if ch == '[' {
+			err = json.NewDecoder(br).Decode(&recs)
+			return recs, err
+		}
+		break
+	}
+
+	dec := json.NewDecoder(br)
+	for {
+		var rec IdeaRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return recs, nil
+		} else if err != nil {
+			return recs, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// ImportJSON recreates the ideas from records. Ids are remapped to new ids
+// unless preserveIds is true, in which case ids are only remapped where they
+// collide with existing ideas. All consumes ids and [[id]] links between the
+// imported ideas are rewritten with the remapped ids, consumes ids of ideas
+// which are not imported are dropped and links to them are left as the plain
+// id. Relative content paths are relative to baseDir.
+func ImportJSON(recs []IdeaRecord, baseDir string, preserveIds bool) (imported idea.Ideas, err error) {
+
+	// validate everything before writing anything
+	idears := make(idea.Ideas, len(recs))
+	ids := make([]uint32, len(recs))
+	for i, rec := range recs {
+		idears[i], err = rec.ToIdea()
+		if err != nil {
+			return imported, err
+		}
+		if rec.Content == nil && rec.Path == "" {
+			return imported, fmt.Errorf("record %v has neither content nor a path", rec.Id)
+		}
+		if rec.Content == nil && !cmn.FileExists(recordPath(rec, baseDir)) {
+			return imported, fmt.Errorf("record %v content not found at %v", rec.Id, rec.Path)
+		}
+		ids[i] = rec.Id
+	}
+	seen := make(map[uint32]bool)
+	for _, id := range ids {
+		if seen[id] {
+			return imported, fmt.Errorf("duplicate id %v within records", id)
+		}
+		seen[id] = true
+	}
+
+	remap := AllocateIds(ids, preserveIds)
+	for i, rec := range recs {
+		idear := idears[i]
+		idear.Id = remap.Id(idear.Id)
+		idear.ConsumesIds = remap.ForeignIds(idear.ConsumesIds)
+		(&idear).UpdateFilename()
+
+		if rec.Content != nil && idear.Kind == KindEnText {
+			// decrypted content of an encrypted idea is encrypted once again
+			passphrase, err := GetPassphrase(true)
+			if err != nil {
+				return imported, err
+			}
+			bz, err := Encrypt([]byte(remap.ForeignLinks(*rec.Content)), passphrase)
+			if err != nil {
+				return imported, err
+			}
+			err = ioutil.WriteFile(idear.Path(), bz, 0600)
+			if err != nil {
+				return imported, err
+			}
+		} else if rec.Content != nil {
+			WriteIdea(idear.Filename, remap.ForeignLinks(*rec.Content))
+		} else {
+			err = cmn.Copy(recordPath(rec, baseDir), idear.Path())
+			if err != nil {
+				return imported, err
+			}
+		}
+		imported = append(imported, idear)
+	}
+	if len(imported) == 0 {
+		return imported, errors.New("no records to import")
+	}
+	return imported, nil
+}
+
+func recordPath(rec IdeaRecord, baseDir string) string {
+	if path.IsAbs(rec.Path) {
+		return rec.Path
+	}
+	return path.Join(baseDir, rec.Path)
+}
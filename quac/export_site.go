@@ -0,0 +1,398 @@
+package quac
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+type SiteOptions struct {
+	IncludeConsumed  bool // include consumed (and zombie) ideas
+	IncludeEncrypted bool // include decrypted encrypted ideas, excluded by default
+}
+
+// a page of the site
+type sitePage struct {
+	Title string
+	Root  string // relative path back to the site root
+	Body  template.HTML
+}
+
+// entry within the client-side search index
+type siteSearchEntry struct {
+	Url     string `json:"url"`
+	Title   string `json:"title"`
+	Tags    string `json:"tags"`
+	Created string `json:"created"`
+	Text    string `json:"text"`
+}
+
+// ExportSite builds a browsable static html site of the ideas within outDir
+func ExportSite(idears idea.Ideas, outDir string, opts SiteOptions) error {
+	var exported idea.Ideas
+	for _, idear := range idears {
+		if idear.Cycle != CycleAlive && !opts.IncludeConsumed {
+			continue
+		}
+		if idear.Kind == KindEnText && !opts.IncludeEncrypted {
+			continue
+		}
+		exported = append(exported, idear)
+	}
+	sort.Slice(exported, func(i, j int) bool { return exported[i].Id < exported[j].Id })
+
+	for _, dir := range []string{"", "ideas", "tags", "archive", "files"} {
+		err := os.MkdirAll(path.Join(outDir, dir), os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+
+	s := siteBuilder{
+		outDir:   outDir,
+		exported: make(map[uint32]idea.Idea),
+		lin:      idea.NewLineage(GetAllIdeas()),
+		rels:     idea.GetAllRelations(),
+	}
+	var tags []string
+	for _, idear := range exported {
+		s.exported[idear.Id] = idear
+		for _, tag := range idear.Tags {
+			tags = append(tags, tag.String())
+		}
+	}
+	s.slugs = siteSlugs(tags)
+
+	var search []siteSearchEntry
+	for _, idear := range exported {
+		entry, err := s.writeIdeaPage(idear)
+		if err != nil {
+			return err
+		}
+		search = append(search, entry)
+	}
+	if err := s.writeTagPages(exported); err != nil {
+		return err
+	}
+	if err := s.writeArchivePages(exported); err != nil {
+		return err
+	}
+	if err := s.writeSearch(search); err != nil {
+		return err
+	}
+	return s.writeIndex(exported)
+}
+
+type siteBuilder struct {
+	outDir   string
+	exported map[uint32]idea.Idea
+	lin      idea.Lineage
+	rels     idea.Relations
+	slugs    map[string]string // tag page slugs by tag
+}
+
+func siteIdeaHref(root string, id uint32) string {
+	return root + "ideas/" + idea.IdStr(id) + ".html"
+}
+
+func (s siteBuilder) tagHref(root string, tag string) string {
+	return root + "tags/" + s.slugs[tag] + ".html"
+}
+
+// unique slugs for the tags, a tag whose slug is already taken (such as foo=bar
+// and foo_bar) is suffixed with a number in the sorted order of the tags
+func siteSlugs(tags []string) map[string]string {
+	sort.Strings(tags)
+	slugs := make(map[string]string)
+	taken := map[string]bool{"index": true} // the tag index page
+	for _, tag := range tags {
+		if _, found := slugs[tag]; found {
+			continue
+		}
+		slug := siteSlug(tag)
+		for n := 2; taken[strings.ToLower(slug)]; n++ { // case insensitive filesystems
+			slug = fmt.Sprintf("%v-%v", siteSlug(tag), n)
+		}
+		taken[strings.ToLower(slug)] = true
+		slugs[tag] = slug
+	}
+	return slugs
+}
+
+// file safe version of a tag
+func siteSlug(in string) string {
+	return strings.Map(func(ch rune) rune {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z',
+			ch >= '0' && ch <= '9', ch == '-', ch == '_', ch == '.':
+			return ch
+		}
+		return '_'
+	}, in)
+}
+
+func siteIdeaTitle(idear idea.Idea) string {
+	return idea.IdStr(idear.Id) + " " + idear.GetClumpedTags()
+}
+
+// link to an idea if it is exported otherwise just the id
+func (s siteBuilder) ideaLink(root string, id uint32) string {
+	idear, found := s.exported[id]
+	if !found {
+		return html.EscapeString(idea.IdStr(id))
+	}
+	return fmt.Sprintf(`<a href="%v">%v</a>`, siteIdeaHref(root, id),
+		html.EscapeString(siteIdeaTitle(idear)))
+}
+
+func (s siteBuilder) writeIdeaPage(idear idea.Idea) (entry siteSearchEntry, err error) {
+	root := "../"
+	body := "<p class=\"meta\">"
+	body += fmt.Sprintf("%v &middot; created %v &middot; edited %v",
+		idea.KindName(idear.Kind), idear.Created.Format(cmn.LayoutYYYYdMMdDD),
+		idear.Edited.Format(cmn.LayoutYYYYdMMdDD))
+	if idear.Cycle != CycleAlive {
+		body += fmt.Sprintf(" &middot; %v %v", idea.CycleName(idear.Cycle),
+			idear.Consumed.Format(cmn.LayoutYYYYdMMdDD))
+	}
+	body += "</p>\n<p class=\"tags\">"
+	for _, tag := range idear.Tags {
+		body += fmt.Sprintf(`<a href="%v">%v</a> `, s.tagHref(root, tag.String()),
+			html.EscapeString(tag.String()))
+	}
+	body += "</p>\n"
+
+	var text string
+	switch idear.Kind {
+	case KindText:
+		text = string(idear.GetContent())
+		body += "<pre class=\"content\">" + s.linkify(root, text) + "</pre>\n"
+	case KindImage, KindAudio:
+		err = cmn.Copy(idear.Path(), path.Join(s.outDir, "files", idear.Filename))
+		if err != nil {
+			return entry, err
+		}
+		src := root + "files/" + url.PathEscape(idear.Filename)
+		if idear.IsImage() {
+			body += fmt.Sprintf("<img class=\"content\" src=\"%v\">\n", src)
+		} else {
+			body += fmt.Sprintf("<audio controls src=\"%v\"></audio>\n", src)
+		}
+	case KindEnText:
+		if IsLegacyEncrypted(idear.GetContent()) {
+			body += "<p class=\"content\"><em>encrypted content</em></p>\n"
+			break
+		}
+		bz, err := idear.GetPlainContent()
+		if err != nil {
+			return entry, err
+		}
+		text = string(bz)
+		body += "<pre class=\"content\">" + s.linkify(root, text) + "</pre>\n"
+	}
+
+	// lineage and relations
+	var related []string
+	for _, pid := range s.lin.Parents(idear.Id) {
+		related = append(related, "consumes "+s.ideaLink(root, pid))
+	}
+	for _, cid := range s.lin.Children(idear.Id) {
+		related = append(related, "consumed by "+s.ideaLink(root, cid))
+	}
+	for _, rel := range s.rels.Involving(idear.Id) {
+		if rel.From == idear.Id {
+			related = append(related, rel.Type+" "+s.ideaLink(root, rel.To))
+		} else {
+			related = append(related, s.ideaLink(root, rel.From)+" "+rel.Type+" this")
+		}
+	}
+	if len(related) > 0 {
+		body += "<h2>lineage</h2>\n<ul>\n"
+		for _, r := range related {
+			body += "<li>" + r + "</li>\n"
+		}
+		body += "</ul>\n"
+	}
+
+	err = s.writePage(path.Join("ideas", idea.IdStr(idear.Id)+".html"),
+		sitePage{Title: siteIdeaTitle(idear), Root: root, Body: template.HTML(body)})
+	entry = siteSearchEntry{
+		Url:     siteIdeaHref("", idear.Id),
+		Title:   siteIdeaTitle(idear),
+		Tags:    idear.GetClumpedTags(),
+		Created: idear.Created.Format(cmn.LayoutYYYYdMMdDD),
+		Text:    text,
+	}
+	return entry, err
+}
+
+// escape the text and convert [[id]] links into hyperlinks
+func (s siteBuilder) linkify(root, text string) string {
+	return idea.ReplaceLinks(html.EscapeString(text), func(id uint32, linkText string) string {
+		if _, found := s.exported[id]; !found {
+			return linkText
+		}
+		return fmt.Sprintf(`<a href="%v">%v</a>`, siteIdeaHref(root, id), linkText)
+	})
+}
+
+func (s siteBuilder) ideaList(root string, idears idea.Ideas) string {
+	out := "<ul>\n"
+	for _, idear := range idears {
+		out += "<li>" + s.ideaLink(root, idear.Id) + "</li>\n"
+	}
+	return out + "</ul>\n"
+}
+
+func (s siteBuilder) writeTagPages(idears idea.Ideas) error {
+	byTag := make(map[string]idea.Ideas)
+	for _, idear := range idears {
+		for _, tag := range idear.Tags {
+			byTag[tag.String()] = append(byTag[tag.String()], idear)
+		}
+	}
+	var tags []string
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	root := "../"
+	index := "<ul>\n"
+	for _, tag := range tags {
+		index += fmt.Sprintf("<li><a href=\"%v\">%v</a> (%v)</li>\n",
+			s.tagHref(root, tag), html.EscapeString(tag), len(byTag[tag]))
+		err := s.writePage(path.Join("tags", s.slugs[tag]+".html"), sitePage{
+			Title: "tag: " + tag, Root: root,
+			Body: template.HTML(s.ideaList(root, byTag[tag]))})
+		if err != nil {
+			return err
+		}
+	}
+	index += "</ul>\n"
+	return s.writePage(path.Join("tags", "index.html"),
+		sitePage{Title: "tags", Root: root, Body: template.HTML(index)})
+}
+
+func (s siteBuilder) writeArchivePages(idears idea.Ideas) error {
+	byMonth := make(map[string]idea.Ideas)
+	for _, idear := range idears {
+		month := idear.Created.Format("2006-01")
+		byMonth[month] = append(byMonth[month], idear)
+	}
+	var months []string
+	for month := range byMonth {
+		months = append(months, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+
+	root := "../"
+	index := "<ul>\n"
+	for _, month := range months {
+		index += fmt.Sprintf("<li><a href=\"%v.html\">%v</a> (%v)</li>\n",
+			month, month, len(byMonth[month]))
+		err := s.writePage(path.Join("archive", month+".html"), sitePage{
+			Title: "archive: " + month, Root: root,
+			Body: template.HTML(s.ideaList(root, byMonth[month]))})
+		if err != nil {
+			return err
+		}
+	}
+	index += "</ul>\n"
+	return s.writePage(path.Join("archive", "index.html"),
+		sitePage{Title: "archive", Root: root, Body: template.HTML(index)})
+}
+
+// the search index is written as javascript so that the site may be
+// browsed directly from the filesystem
+func (s siteBuilder) writeSearch(entries []siteSearchEntry) error {
+	if entries == nil {
+		entries = []siteSearchEntry{}
+	}
+	bz, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path.Join(s.outDir, "search-index.js"),
+		[]byte("var searchIndex = "+string(bz)+";\n"), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return s.writePage("search.html", sitePage{Title: "search", Root: "",
+		Body: template.HTML(siteSearchBody)})
+}
+
+func (s siteBuilder) writeIndex(idears idea.Ideas) error {
+	recent := make(idea.Ideas, len(idears))
+	copy(recent, idears)
+	sort.SliceStable(recent, func(i, j int) bool { return recent[i].Edited.After(recent[j].Edited) })
+	if len(recent) > 50 {
+		recent = recent[:50]
+	}
+	body := fmt.Sprintf("<p>%v ideas</p>\n<h2>recently edited</h2>\n", len(idears))
+	body += s.ideaList("", recent)
+	return s.writePage("index.html", sitePage{Title: "ranch", Root: "", Body: template.HTML(body)})
+}
+
+func (s siteBuilder) writePage(relPath string, page sitePage) error {
+	f, err := os.Create(path.Join(s.outDir, relPath))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return siteTemplate.Execute(f, page)
+}
+
+var siteTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; }
+nav a { margin-right: 1em; }
+pre.content { white-space: pre-wrap; font-size: 1.1em; }
+img.content { max-width: 100%; }
+.meta, .tags { color: #666; }
+</style>
+</head>
+<body>
+<nav><a href="{{.Root}}index.html">home</a><a href="{{.Root}}tags/index.html">tags</a><a href="{{.Root}}archive/index.html">archive</a><a href="{{.Root}}search.html">search</a></nav>
+<h1>{{.Title}}</h1>
+{{.Body}}
+</body>
+</html>
+`))
+
+const siteSearchBody = `<input id="q" type="search" placeholder="search" autofocus>
+<ul id="results"></ul>
+<script src="search-index.js"></script>
+<script>
+document.getElementById("q").addEventListener("input", function(e) {
+  var q = e.target.value.toLowerCase();
+  var results = document.getElementById("results");
+  results.innerHTML = "";
+  if (q.length < 2) { return; }
+  searchIndex.forEach(function(entry) {
+    var haystack = (entry.title + " " + entry.text).toLowerCase();
+    if (haystack.indexOf(q) < 0) { return; }
+    var li = document.createElement("li");
+    var a = document.createElement("a");
+    a.href = entry.url;
+    a.textContent = entry.title + " (" + entry.created + ")";
+    li.appendChild(a);
+    results.appendChild(li);
+  });
+});
+</script>
+`
@@ -0,0 +1,20 @@
+package quac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSiteSlugs(t *testing.T) {
+	slugs := siteSlugs([]string{"foo_bar", "foo=bar", "index", "Foo", "foo", "foo=bar"})
+	expected := map[string]string{
+		"Foo":     "Foo",
+		"foo":     "foo-2",
+		"foo=bar": "foo_bar",
+		"foo_bar": "foo_bar-2",
+		"index":   "index-2",
+	}
+	if !reflect.DeepEqual(slugs, expected) {
+		t.Errorf("expected slugs %v, got %v", expected, slugs)
+	}
+}
@@ -0,0 +1,287 @@
+package quac
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// supported graph output formats
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatGraphML = "graphml"
+	GraphFormatJSON    = "json"
+)
+
+// edge types
+const (
+	EdgeConsumes = "consumes" // consumer -> consumed
+	EdgeLink     = "link"     // idea containing [[id]] -> linked idea
+	EdgeRelation = "relation" // typed relation, see Relations
+	EdgeTagged   = "tagged"   // idea -> tag
+	EdgeCooccurs = "cooccurs" // tag -- tag, weighted by shared ideas
+)
+
+const (
+	tagNodePrefix = "tag:"
+	nodeTypeIdea  = "idea"
+	nodeTypeTag   = "tag"
+)
+
+type GraphNode struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"` // idea or tag
+	Label    string `json:"label"`
+	Kind     string `json:"kind,omitempty"`
+	Cycle    string `json:"cycle,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Edited   string `json:"edited,omitempty"`
+	Consumed string `json:"consumed,omitempty"`
+}
+
+type GraphEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Type     string `json:"type"`
+	Relation string `json:"relation,omitempty"`
+	Weight   int    `json:"weight"`
+}
+
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// NewGraph creates the graph of the ideas, edges are only included between
+// ideas which are both within the provided ideas. If includeTags is true tags
+// are included as nodes along with the co-occurrence of tags as weighted edges.
+func NewGraph(idears idea.Ideas, includeTags bool) (g Graph) {
+	inGraph := make(map[uint32]bool)
+	for _, idear := range idears {
+		inGraph[idear.Id] = true
+	}
+
+	// idea nodes
+	for _, idear := range idears {
+		node := GraphNode{
+			Id:      idea.IdStr(idear.Id),
+			Type:    nodeTypeIdea,
+			Label:   idea.IdStr(idear.Id) + " " + idear.GetClumpedTags(),
+			Kind:    idea.KindName(idear.Kind),
+			Cycle:   idea.CycleName(idear.Cycle),
+			Created: idear.Created.Format(cmn.LayoutYYYYdMMdDD),
+			Edited:  idear.Edited.Format(cmn.LayoutYYYYdMMdDD),
+		}
+		if idear.Cycle != CycleAlive {
+			node.Consumed = idear.Consumed.Format(cmn.LayoutYYYYdMMdDD)
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	// consumption edges
+	lin := idea.NewLineage(idears)
+	for _, idear := range idears {
+		for _, pid := range lin.Parents(idear.Id) {
+			if inGraph[pid] {
+				g.Edges = append(g.Edges, GraphEdge{
+					Source: idea.IdStr(idear.Id), Target: idea.IdStr(pid),
+					Type: EdgeConsumes, Weight: 1})
+			}
+		}
+	}
+
+	// explicit links
+	for _, idear := range idears {
+		for _, lid := range idear.GetLinks() {
+			if inGraph[lid] && lid != idear.Id {
+				g.Edges = append(g.Edges, GraphEdge{
+					Source: idea.IdStr(idear.Id), Target: idea.IdStr(lid),
+					Type: EdgeLink, Weight: 1})
+			}
+		}
+	}
+	for _, rel := range idea.GetAllRelations() {
+		if inGraph[rel.From] && inGraph[rel.To] {
+			g.Edges = append(g.Edges, GraphEdge{
+				Source: idea.IdStr(rel.From), Target: idea.IdStr(rel.To),
+				Type: EdgeRelation, Relation: rel.Type, Weight: 1})
+		}
+	}
+
+	if !includeTags {
+		return g
+	}
+
+	// tag nodes
+	tagCounts := make(map[string]int)
+	tagsByStr := make(map[string]idea.Tag)
+	for _, idear := range idears {
+		var tagStrs []string
+		for _, tag := range idear.Tags {
+			tagStrs = append(tagStrs, tag.String())
+			tagsByStr[tag.String()] = tag
+		}
+		sort.Strings(tagStrs)
+		for _, tagStr := range tagStrs {
+			tagCounts[tagStr]++
+			g.Edges = append(g.Edges, GraphEdge{
+				Source: idea.IdStr(idear.Id), Target: tagNodePrefix + tagStr,
+				Type: EdgeTagged, Weight: 1})
+		}
+	}
+
+	var tagStrs []string
+	for tagStr := range tagCounts {
+		tagStrs = append(tagStrs, tagStr)
+	}
+	sort.Strings(tagStrs)
+	for _, tagStr := range tagStrs {
+		g.Nodes = append(g.Nodes, GraphNode{
+			Id:    tagNodePrefix + tagStr,
+			Type:  nodeTypeTag,
+			Label: fmt.Sprintf("%v (%v)", tagStr, tagCounts[tagStr]),
+		})
+	}
+
+	// the co-occurrence between tags, as associated within the browser
+	cooccurs := make(map[[2]string]int)
+	for _, tagStr := range tagStrs {
+		tags := []idea.Tag{tagsByStr[tagStr]}
+		for _, assoc := range associatedTagCounts(idears.WithTags(tags), tags) {
+			if tagStr < assoc.Key {
+				cooccurs[[2]string{tagStr, assoc.Key}] = assoc.Value
+			}
+		}
+	}
+
+	var pairs [][2]string
+	for pair := range cooccurs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] == pairs[j][0] {
+			return pairs[i][1] < pairs[j][1]
+		}
+		return pairs[i][0] < pairs[j][0]
+	})
+	for _, pair := range pairs {
+		g.Edges = append(g.Edges, GraphEdge{
+			Source: tagNodePrefix + pair[0], Target: tagNodePrefix + pair[1],
+			Type: EdgeCooccurs, Weight: cooccurs[pair]})
+	}
+	return g
+}
+
+// write the graph in the provided format
+func (g Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case GraphFormatDOT:
+		return g.WriteDOT(w)
+	case GraphFormatGraphML:
+		return g.WriteGraphML(w)
+	case GraphFormatJSON:
+		return g.WriteJSON(w)
+	}
+	return fmt.Errorf("unknown graph format %v, must be one of %v, %v, %v",
+		format, GraphFormatDOT, GraphFormatGraphML, GraphFormatJSON)
+}
+
+// write the graph for rendering with graphviz
+func (g Graph) WriteDOT(w io.Writer) error {
+	quote := func(in string) string {
+		return `"` + strings.Replace(strings.Replace(in, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+	}
+
+	out := "digraph ranch {\n"
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + quote(n.Label), "type=" + quote(n.Type)}
+		if n.Type == nodeTypeTag {
+			attrs = append(attrs, "shape=box")
+		} else {
+			attrs = append(attrs, "kind="+quote(n.Kind), "cycle="+quote(n.Cycle),
+				"created="+quote(n.Created), "edited="+quote(n.Edited))
+			if n.Consumed != "" {
+				attrs = append(attrs, "consumed="+quote(n.Consumed))
+			}
+			if n.Cycle != idea.CycleName(CycleAlive) {
+				attrs = append(attrs, "style=dashed")
+			}
+		}
+		out += fmt.Sprintf("  %v [%v];\n", quote(n.Id), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{"type=" + quote(e.Type), fmt.Sprintf("weight=%v", e.Weight)}
+		switch e.Type {
+		case EdgeRelation:
+			attrs = append(attrs, "label="+quote(e.Relation), "relation="+quote(e.Relation))
+		case EdgeLink:
+			attrs = append(attrs, "style=dotted")
+		case EdgeCooccurs:
+			attrs = append(attrs, "dir=none", fmt.Sprintf("penwidth=%v", e.Weight))
+		}
+		out += fmt.Sprintf("  %v -> %v [%v];\n", quote(e.Source), quote(e.Target),
+			strings.Join(attrs, ", "))
+	}
+	out += "}\n"
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// write the graph as GraphML for importing into graph tools
+func (g Graph) WriteGraphML(w io.Writer) error {
+	escape := func(in string) string {
+		var sb strings.Builder
+		_ = xml.EscapeText(&sb, []byte(in))
+		return sb.String()
+	}
+	data := func(key, value string) string {
+		if value == "" {
+			return ""
+		}
+		return fmt.Sprintf(`<data key="%v">%v</data>`, key, escape(value))
+	}
+
+	out := xml.Header
+	out += `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n"
+	for _, key := range []string{"type", "label", "kind", "cycle", "created", "edited", "consumed"} {
+		out += fmt.Sprintf(`  <key id="%v" for="node" attr.name="%v" attr.type="string"/>`+"\n", key, key)
+	}
+	out += `  <key id="etype" for="edge" attr.name="type" attr.type="string"/>` + "\n"
+	out += `  <key id="relation" for="edge" attr.name="relation" attr.type="string"/>` + "\n"
+	out += `  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>` + "\n"
+	out += `  <graph id="ranch" edgedefault="directed">` + "\n"
+	for _, n := range g.Nodes {
+		out += fmt.Sprintf(`    <node id="%v">%v%v%v%v%v%v%v</node>`+"\n", escape(n.Id),
+			data("type", n.Type), data("label", n.Label), data("kind", n.Kind),
+			data("cycle", n.Cycle), data("created", n.Created),
+			data("edited", n.Edited), data("consumed", n.Consumed))
+	}
+	for i, e := range g.Edges {
+		directed := e.Type != EdgeCooccurs
+		out += fmt.Sprintf(`    <edge id="e%v" source="%v" target="%v" directed="%v">%v%v%v</edge>`+"\n",
+			i, escape(e.Source), escape(e.Target), directed, data("etype", e.Type),
+			data("relation", e.Relation), data("weight", fmt.Sprintf("%v", e.Weight)))
+	}
+	out += "  </graph>\n</graphml>\n"
+	_, err := io.WriteString(w, out)
+	return err
+}
+
+// write the graph as json in the format {"nodes": [...], "edges": [...]}
+func (g Graph) WriteJSON(w io.Writer) error {
+	if g.Nodes == nil {
+		g.Nodes = []GraphNode{}
+	}
+	if g.Edges == nil {
+		g.Edges = []GraphEdge{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
@@ -66,16 +66,28 @@ func GetIdeaByID(id uint32, loglast bool) idea.Idea {
 }
 
 func RemoveByID(id uint32) {
-	existingFp, trashFp, found := GetTrashcanFilepathsByID(id)
-	if !found {
+	if _, _, found := GetTrashcanFilepathsByID(id); !found {
 		fmt.Println("nothing found at that ID")
 		os.Exit(1)
 	}
-	if err := os.Rename(existingFp, trashFp); err != nil {
+	if err := trashByID(id); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// move the idea into the trash can
+func trashByID(id uint32) error {
+	existingFp, trashFp, found := GetTrashcanFilepathsByID(id)
+	if !found {
+		return fmt.Errorf("no idea found with id %v", idea.IdStr(id))
+	}
+	// the draft of a trashed image can be regenerated if it's ever restored
+	if err := idea.NewIdeaFromFilename(path.Base(existingFp), false).RemoveOCRDraft(); err != nil {
+		return err
+	}
+	return os.Rename(existingFp, trashFp)
+}
+
 // copy an idea by the id
 func CopyByID(id uint32) (newFilepath string) {
 	fn := GetFilenameByID(id)
@@ -0,0 +1,192 @@
+package idea
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted ideas (.en) are encrypted with AES-256-GCM using a key derived
+// from a passphrase with PBKDF2-HMAC-SHA256. The file format is the magic,
+// salt (16 bytes), iterations (4 bytes, big-endian), nonce (12 bytes) and then
+// the ciphertext. The header (everything before the ciphertext) is
+// authenticated along with the content. Files encrypted by vim's :X (legacy)
+// begin with "VimCrypt~".
+const (
+	cryptSaltLen    = 16
+	cryptNonceLen   = 12
+	cryptKeyLen     = 32
+	CryptIterations = 200000
+
+	// the header isn't authenticated until after the key is derived, so the
+	// iterations of a tampered header are capped
+	cryptMaxIterations = 10 * CryptIterations
+)
+
+var (
+	CryptMagic       = []byte("THRANCH-EN1\n")
+	legacyCryptMagic = []byte("VimCrypt~")
+
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted encrypted idea")
+
+	// Passphrase provides the passphrase for encrypted ideas, if confirm is
+	// true a new passphrase is being set. Nil if no passphrase can be provided.
+	Passphrase func(confirm bool) ([]byte, error)
+
+	// when true CONTAINS tags also search the content of encrypted ideas
+	IncludeEncrypted bool
+)
+
+// IsNativeEncrypted returns true if the content was encrypted by thranch
+func IsNativeEncrypted(bz []byte) bool {
+	return bytes.HasPrefix(bz, CryptMagic)
+}
+
+// IsLegacyEncrypted returns true if the content was encrypted by vim
+func IsLegacyEncrypted(bz []byte) bool {
+	return bytes.HasPrefix(bz, legacyCryptMagic)
+}
+
+// Encrypt the plaintext with the passphrase
+func Encrypt(plaintext, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, cryptSaltLen)
+	nonce := make([]byte, cryptNonceLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	iterBz := make([]byte, 4)
+	binary.BigEndian.PutUint32(iterBz, CryptIterations)
+
+	header := append(append(append(append([]byte{}, CryptMagic...), salt...), iterBz...), nonce...)
+	aead, err := newCryptAEAD(passphrase, salt, CryptIterations)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, plaintext, header), nil
+}
+
+// Decrypt content encrypted by Encrypt
+func Decrypt(bz, passphrase []byte) ([]byte, error) {
+	if !IsNativeEncrypted(bz) {
+		return nil, errors.New("not a thranch encrypted idea")
+	}
+	headerLen := len(CryptMagic) + cryptSaltLen + 4 + cryptNonceLen
+	if len(bz) < headerLen {
+		return nil, ErrWrongPassphrase
+	}
+	salt := bz[len(CryptMagic) : len(CryptMagic)+cryptSaltLen]
+	iterations := binary.BigEndian.Uint32(bz[len(CryptMagic)+cryptSaltLen:])
+	nonce := bz[headerLen-cryptNonceLen : headerLen]
+	if iterations == 0 || iterations > cryptMaxIterations {
+		return nil, ErrWrongPassphrase
+	}
+	aead, err := newCryptAEAD(passphrase, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, bz[headerLen:], bz[:headerLen])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func newCryptAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	return newKeyAEAD(pbkdf2.Key(passphrase, salt, iterations, cryptKeyLen, sha256.New))
+}
+
+// DeriveKey derives a key from the passphrase for use with SealWithKey
+func DeriveKey(passphrase, salt []byte) []byte {
+	return pbkdf2.Key(passphrase, salt, CryptIterations, cryptKeyLen, sha256.New)
+}
+
+// SealWithKey encrypts the plaintext with a derived key, the output is the
+// random nonce followed by the ciphertext
+func SealWithKey(key, plaintext []byte) ([]byte, error) {
+	aead, err := newKeyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, cryptNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// OpenWithKey decrypts content encrypted by SealWithKey
+func OpenWithKey(key, bz []byte) ([]byte, error) {
+	aead, err := newKeyAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) < cryptNonceLen {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := aead.Open(nil, bz[:cryptNonceLen], bz[cryptNonceLen:], nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func newKeyAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// GetPlainContent returns the content of the idea, decrypting encrypted ideas
+func (idea Idea) GetPlainContent() ([]byte, error) {
+	bz := idea.GetContent()
+	if idea.Kind != KindEnText {
+		return bz, nil
+	}
+	if IsLegacyEncrypted(bz) {
+		return nil, errors.New("vim encrypted ideas can only be opened with vim: " + idea.Filename)
+	}
+	if Passphrase == nil {
+		return nil, errors.New("no passphrase available to decrypt " + idea.Filename)
+	}
+	passphrase, err := Passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	return Decrypt(bz, passphrase)
+}
+
+// content searched by CONTAINS tags, encrypted ideas are only searched when
+// IncludeEncrypted is set (legacy vim encrypted ideas are never searched) and
+// image ideas by their OCR draft when SearchOCRDrafts is set. Encrypted ideas
+// which cannot be decrypted are reported and skipped (ok is false).
+func (idea Idea) searchableContent() (bz []byte, ok bool) {
+	if idea.Kind == KindImage && SearchOCRDrafts {
+		draft, _ := idea.GetOCRDraft()
+		return []byte(draft), true
+	}
+	if idea.Kind != KindEnText {
+		return idea.GetContent(), true
+	}
+	if !IncludeEncrypted || IsLegacyEncrypted(idea.GetContent()) {
+		return nil, true
+	}
+	bz, err := idea.GetPlainContent()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "skipping %v: %v\n", idea.Filename, err)
+		return nil, false
+	}
+	return bz, true
+}
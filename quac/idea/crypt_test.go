@@ -0,0 +1,50 @@
+package idea
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 with 200000 iterations, the key of existing vaults
+	// and encrypted ideas must never change
+	expected := "7cbcd40f6980961d620da6d3e868dc48f3d36177bae4c555262df2b07523fd85"
+	key := DeriveKey([]byte("passwd"), []byte("salt"))
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("unexpected key %x", key)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte("the secret idea\n")
+	bz, err := Encrypt(plaintext, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsNativeEncrypted(bz) || bytes.Contains(bz, plaintext) {
+		t.Fatal("content not encrypted")
+	}
+
+	decrypted, err := Decrypt(bz, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("decrypted %q, expected %q", decrypted, plaintext)
+	}
+
+	if _, err := Decrypt(bz, []byte("hunter3")); err != ErrWrongPassphrase {
+		t.Errorf("expected wrong passphrase error, got %v", err)
+	}
+
+	// the header is authenticated along with the ciphertext, a tampered
+	// iteration count is refused before deriving the key
+	for _, i := range []int{len(CryptMagic), len(CryptMagic) + cryptSaltLen, len(bz) - 1} {
+		tampered := append([]byte{}, bz...)
+		tampered[i] ^= 1
+		if _, err := Decrypt(tampered, []byte("hunter2")); err == nil {
+			t.Errorf("tampering with byte %v not detected", i)
+		}
+	}
+}
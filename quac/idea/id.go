@@ -34,6 +34,9 @@ func GetIdByFilename(filename string) (id uint32, skip bool) {
 }
 
 func GetNextID() uint32 {
+	if DeviceName != "" {
+		return deviceNextID()
+	}
 	lines, err := cmn.ReadLines(ConfigFile)
 	if err != nil {
 		panic(fmt.Sprintf("error reading config, error: %v", err))
@@ -46,12 +49,31 @@ func GetNextID() uint32 {
 }
 
 func IncrementID() {
+	if DeviceName != "" {
+		deviceIncrementID()
+		return
+	}
 	err := cmn.WriteLines([]string{IdStr(GetNextID())}, ConfigFile)
 	if err != nil {
 		panic(err)
 	}
 }
 
+// ensure that the ids provided from GetNextID will be greater than the id
+func EnsureIDCounterAtLeast(id uint32) {
+	if DeviceName != "" {
+		deviceEnsureIDCounterAtLeast(id)
+		return
+	}
+	if GetNextID() > id {
+		return
+	}
+	err := cmn.WriteLines([]string{IdStr(id)}, ConfigFile)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // parse the last id, if no error add to the last ids file
 func ParseID(idStr string) (uint32, error) {
 	return ParseIDOp(idStr, true)
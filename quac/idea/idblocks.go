@@ -0,0 +1,337 @@
+package idea
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+)
+
+// When a device name is configured ids are allocated from blocks of ids
+// reserved by each device within the shared id blocks file, so that ideas
+// created on different devices never share an id. Blocks are only reserved
+// where the id blocks file is up to date with every device (a sync), never
+// offline. A device may instead be configured with a static range of ids.
+// Ids remain within six digits.
+
+const (
+	MaxID               = 999999
+	DefaultIdBlockSize  = 1000
+	idBlockFieldsPerRow = 4
+)
+
+var (
+	DeviceName   string // no device name means the single counter within the config is used
+	DeviceStart  uint32 // static range for this device, zero if blocks are reserved
+	DeviceEnd    uint32
+	IdBlockSize  uint32 = DefaultIdBlockSize
+	IdBlocksFile string
+)
+
+// IdBlock is a block of ids reserved by a device, each block is recorded on
+// its own line within the id blocks file in the format: device,start,end,last
+type IdBlock struct {
+	Device string
+	Start  uint32
+	End    uint32
+	Last   uint32 // last id allocated from the block, Start-1 if none
+}
+
+func (b IdBlock) String() string {
+	return fmt.Sprintf("%v,%v,%v,%v", b.Device, IdStr(b.Start), IdStr(b.End), IdStr(b.Last))
+}
+
+func (b IdBlock) Exhausted() bool {
+	return b.Last >= b.End
+}
+
+func (b IdBlock) overlaps(start, end uint32) bool {
+	return b.Start <= end && start <= b.End
+}
+
+// ParseDeviceRange parses a static id range in the format start-end
+func ParseDeviceRange(in string) (start, end uint32, err error) {
+	split := strings.Split(in, "-")
+	if len(split) != 2 {
+		return 0, 0, fmt.Errorf("bad id range %v, must be in the format start-end", in)
+	}
+	s, err1 := strconv.Atoi(split[0])
+	e, err2 := strconv.Atoi(split[1])
+	if err1 != nil || err2 != nil || s < 1 || e < s || e > MaxID {
+		return 0, 0, fmt.Errorf("bad id range %v, must be between 000001 and %v", in, MaxID)
+	}
+	return uint32(s), uint32(e), nil
+}
+
+func GetIdBlocks() (blocks []IdBlock) {
+	blocks, err := ReadIdBlocks(IdBlocksFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return blocks
+}
+
+// ReadIdBlocks reads the id blocks file at the path, no file has no blocks
+func ReadIdBlocks(blocksPath string) (blocks []IdBlock, err error) {
+	if !cmn.FileExists(blocksPath) {
+		return blocks, nil
+	}
+	lines, err := cmn.ReadLines(blocksPath)
+	if err != nil {
+		return blocks, err
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		split := strings.Split(line, ",")
+		if len(split) != idBlockFieldsPerRow {
+			return blocks, fmt.Errorf("bad id block: %v", line)
+		}
+		var nums [3]uint32
+		for i, numStr := range split[1:] {
+			num, err := strconv.Atoi(numStr)
+			if err != nil {
+				return blocks, fmt.Errorf("bad id block: %v", line)
+			}
+			nums[i] = uint32(num)
+		}
+		blocks = append(blocks, IdBlock{split[0], nums[0], nums[1], nums[2]})
+	}
+	return blocks, nil
+}
+
+func WriteIdBlocks(blocks []IdBlock) {
+	if err := WriteIdBlocksTo(IdBlocksFile, blocks); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// WriteIdBlocksTo writes the id blocks file at the path
+func WriteIdBlocksTo(blocksPath string, blocks []IdBlock) error {
+	lines := make([]string, len(blocks))
+	for i, b := range blocks {
+		lines[i] = b.String()
+	}
+	return cmn.WriteLines(lines, blocksPath)
+}
+
+// MergeIdBlocks merges the id blocks of two ranches as a union, the last id
+// allocated from a block being the highest of either. Blocks of different
+// devices should never overlap, should they (such as by an edited id blocks
+// file) the block of the later device (by name) is ended at its last
+// allocated id, so that neither device allocates the same ids again.
+func MergeIdBlocks(a, b []IdBlock) (merged []IdBlock, resolved []string) {
+	byRange := make(map[[2]uint32]map[string]int)
+	for _, block := range append(append([]IdBlock{}, a...), b...) {
+		key := [2]uint32{block.Start, block.End}
+		if i, found := byRange[key][block.Device]; found {
+			if block.Last > merged[i].Last {
+				merged[i].Last = block.Last
+			}
+			continue
+		}
+		if byRange[key] == nil {
+			byRange[key] = make(map[string]int)
+		}
+		byRange[key][block.Device] = len(merged)
+		merged = append(merged, block)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Start != merged[j].Start {
+			return merged[i].Start < merged[j].Start
+		}
+		return merged[i].Device < merged[j].Device
+	})
+
+	for i := range merged {
+		for j := i + 1; j < len(merged); j++ {
+			kept, ended := &merged[i], &merged[j]
+			if kept.Device == ended.Device || !kept.overlaps(ended.Start, ended.End) {
+				continue
+			}
+			if ended.Device < kept.Device {
+				kept, ended = ended, kept
+			}
+			// ids already allocated by either are not allocated again
+			if ended.Last > kept.Last {
+				kept.Last = ended.Last
+				if kept.Last > kept.End {
+					kept.Last = kept.End
+				}
+			}
+			if ended.Last < ended.End {
+				ended.End = ended.Last
+				resolved = append(resolved, fmt.Sprintf(
+					"id block of device %v ended at %v as it overlapped a block of device %v",
+					ended.Device, IdStr(ended.Last), kept.Device))
+			}
+		}
+	}
+
+	// blocks ended before any id was allocated are dropped
+	kept := merged[:0]
+	for _, block := range merged {
+		if block.End >= block.Start {
+			kept = append(kept, block)
+		}
+	}
+	return kept, resolved
+}
+
+// AllocateDeviceID allocates the next id of this device from the blocks,
+// reserving a new block above lastID if needed, without writing the blocks.
+// Only used where the blocks are up to date with every device (a sync).
+func AllocateDeviceID(blocks []IdBlock, lastID uint32) ([]IdBlock, uint32) {
+	i, found := findDeviceBlock(blocks)
+	if !found {
+		blocks = reserveDeviceBlock(blocks, lastID)
+		i = len(blocks) - 1
+	}
+	blocks[i].Last++
+	return blocks, blocks[i].Last
+}
+
+// EnsureDeviceBlock reserves a new block for this device when fewer than half
+// a block of ids remain within its blocks, without writing the blocks. Only
+// used where the blocks are up to date with every device (a sync).
+func EnsureDeviceBlock(blocks []IdBlock, lastID uint32) (_ []IdBlock, reserved bool) {
+	remaining := uint32(0)
+	for _, b := range blocks {
+		if b.Device != DeviceName {
+			continue
+		}
+		if DeviceStart != 0 {
+			return blocks, false // the static range is already reserved
+		}
+		if !b.Exhausted() {
+			remaining += b.End - b.Last
+		}
+	}
+	if DeviceStart == 0 && remaining >= IdBlockSize/2 {
+		return blocks, false
+	}
+	return reserveDeviceBlock(blocks, lastID), true
+}
+
+// ReserveIdBlock reserves a new block for this device within the id blocks
+// file, which must be up to date with every other device
+func ReserveIdBlock() IdBlock {
+	blocks := reserveDeviceBlock(GetIdBlocks(), legacyLastID())
+	WriteIdBlocks(blocks)
+	return blocks[len(blocks)-1]
+}
+
+// the index of the block from which this device allocates ids
+func findDeviceBlock(blocks []IdBlock) (int, bool) {
+	for i, b := range blocks {
+		if b.Device == DeviceName && !b.Exhausted() {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// reserve a new block for this device, either its static range or the next
+// block above lastID and all other blocks
+func reserveDeviceBlock(blocks []IdBlock, lastID uint32) []IdBlock {
+	start, end := DeviceStart, DeviceEnd
+	if start == 0 {
+		start = lastID + 1
+		for _, b := range blocks {
+			if b.End >= start {
+				start = b.End + 1
+			}
+		}
+		end = start + IdBlockSize - 1
+		if end > MaxID {
+			end = MaxID
+		}
+		if start > MaxID {
+			log.Fatalf("no ids remain to reserve a block for device %v", DeviceName)
+		}
+	} else {
+		for _, b := range blocks {
+			if b.Device == DeviceName && b.Start == start {
+				log.Fatalf("the id range %v-%v of device %v is exhausted",
+					IdStr(start), IdStr(end), DeviceName)
+			}
+			if b.Device != DeviceName && b.overlaps(start, end) {
+				log.Fatalf("the id range %v-%v of device %v overlaps a block of device %v",
+					IdStr(start), IdStr(end), DeviceName, b.Device)
+			}
+		}
+	}
+	return append(blocks, IdBlock{DeviceName, start, end, start - 1})
+}
+
+// the block from which this device allocates ids, a static range is reserved
+// on first use as it can't overlap another device. Blocks are otherwise only
+// reserved by a sync or `qu id-blocks reserve`, as reserving from an outdated
+// copy of the id blocks could overlap a block reserved by another device.
+func deviceBlock() ([]IdBlock, int) {
+	blocks := GetIdBlocks()
+	if i, found := findDeviceBlock(blocks); found {
+		return blocks, i
+	}
+	if DeviceStart != 0 {
+		blocks = reserveDeviceBlock(blocks, 0)
+		return blocks, len(blocks) - 1
+	}
+	log.Fatalf("device %v has no ids remaining within its id blocks, reserve another "+
+		"block with `qu sync` or `qu id-blocks reserve`", DeviceName)
+	return blocks, 0
+}
+
+// the last id from the single counter within the config
+func legacyLastID() uint32 {
+	lines, err := cmn.ReadLines(ConfigFile)
+	if err != nil {
+		panic(fmt.Sprintf("error reading config, error: %v", err))
+	}
+	count, err := ParseIDNoLogLast(lines[0])
+	if err != nil {
+		panic(fmt.Sprintf("error reading id_counter, error: %v", err))
+	}
+	return count
+}
+
+func setLegacyLastID(id uint32) {
+	err := cmn.WriteLines([]string{IdStr(id)}, ConfigFile)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func deviceNextID() uint32 {
+	blocks, i := deviceBlock()
+	return blocks[i].Last + 1
+}
+
+func deviceIncrementID() {
+	blocks, i := deviceBlock()
+	blocks[i].Last++
+	WriteIdBlocks(blocks)
+
+	// the single counter is kept as the highest id used for compatibility
+	if blocks[i].Last > legacyLastID() {
+		setLegacyLastID(blocks[i].Last)
+	}
+}
+
+// ids at or below the id within a block of this device are no longer allocated
+func deviceEnsureIDCounterAtLeast(id uint32) {
+	blocks := GetIdBlocks()
+	for i, b := range blocks {
+		if b.Device == DeviceName && b.Start <= id && id <= b.End && b.Last < id {
+			blocks[i].Last = id
+			WriteIdBlocks(blocks)
+		}
+	}
+	if id > legacyLastID() {
+		setLegacyLastID(id)
+	}
+}
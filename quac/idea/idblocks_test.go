@@ -0,0 +1,64 @@
+package idea
+
+import "testing"
+
+func TestMergeIdBlocks(t *testing.T) {
+	// each device allocated further from its own block since the last sync
+	local := []IdBlock{
+		{"desktop", 1, 1000, 1000},
+		{"desktop", 1001, 2000, 1010},
+		{"laptop", 2001, 3000, 2001},
+	}
+	remote := []IdBlock{
+		{"desktop", 1001, 2000, 1004},
+		{"laptop", 2001, 3000, 2020},
+	}
+	merged, resolved := MergeIdBlocks(local, remote)
+	expected := []IdBlock{
+		{"desktop", 1, 1000, 1000},
+		{"desktop", 1001, 2000, 1010},
+		{"laptop", 2001, 3000, 2020},
+	}
+	if len(merged) != len(expected) || len(resolved) != 0 {
+		t.Fatalf("unexpected merge %v, %v", merged, resolved)
+	}
+	for i := range expected {
+		if merged[i] != expected[i] {
+			t.Errorf("expected block %v, got %v", expected[i], merged[i])
+		}
+	}
+
+	// overlapping blocks (such as by an edited file) end the later device
+	merged, resolved = MergeIdBlocks(expected, []IdBlock{{"phone", 2001, 3000, 2005}})
+	if len(resolved) != 1 || merged[len(merged)-1] != (IdBlock{"phone", 2001, 2005, 2005}) {
+		t.Errorf("unexpected overlap resolution %v, %v", merged, resolved)
+	}
+}
+
+func TestEnsureDeviceBlock(t *testing.T) {
+	DeviceName = "laptop"
+	defer func() { DeviceName = "" }()
+	blocks := []IdBlock{
+		{"desktop", 1001, 2000, 1010},
+		{"laptop", 2001, 3000, 2400},
+	}
+
+	// more than half a block remains
+	if _, reserved := EnsureDeviceBlock(blocks, 2400); reserved {
+		t.Error("unexpected block reserved")
+	}
+
+	// the next block is reserved above every block
+	blocks[1].Last = 2600
+	blocks, reserved := EnsureDeviceBlock(blocks, 2600)
+	if !reserved || blocks[len(blocks)-1] != (IdBlock{"laptop", 3001, 4000, 3000}) {
+		t.Fatalf("unexpected reservation %v", blocks)
+	}
+
+	// ids are allocated from the first block with any remaining
+	blocks[1].Last = 3000
+	blocks, id := AllocateDeviceID(blocks, 3000)
+	if id != 3001 {
+		t.Errorf("unexpected allocation %v from %v", id, blocks)
+	}
+}
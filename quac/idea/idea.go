@@ -1,6 +1,7 @@
 package idea
 
 import (
+	"fmt"
 	"log"
 	"path"
 	"strconv"
@@ -119,25 +120,88 @@ func NewConsumingTextIdea(consumesIdea Idea) Idea {
 	return idea
 }
 
+// NewMergingTextIdea creates a new idea object which consumes all the provided
+// ideas, inheriting the union of their tags and consumes ids
+func NewMergingTextIdea(consumesIdeas Ideas) Idea {
+
+	todayDate := TodayDate()
+
+	consumesIds := []uint32{}
+	tags := []Tag{}
+	for _, consumesIdea := range consumesIdeas {
+		cids := append([]uint32{}, consumesIdea.ConsumesIds...)
+		for _, cid := range append(cids, consumesIdea.Id) {
+			if !containsId(consumesIds, cid) {
+				consumesIds = append(consumesIds, cid)
+			}
+		}
+		for _, tag := range consumesIdea.Tags {
+			if !containsTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	idea := Idea{
+		Cycle:       CycleAlive,
+		Id:          GetNextID(),
+		ConsumesIds: consumesIds,
+		Kind:        KindText,
+		Created:     todayDate,
+		Edited:      todayDate,
+		Consumed:    zeroDate,
+		Tags:        tags,
+	}
+
+	(&idea).UpdateFilename()
+	return idea
+}
+
+func containsId(ids []uint32, id uint32) bool {
+	for _, id2 := range ids {
+		if id2 == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(tags []Tag, tag Tag) bool {
+	for _, tag2 := range tags {
+		if tag2.String() == tag.String() {
+			return true
+		}
+	}
+	return false
+}
+
 func NewIdeaFromFilepath(filepath string, loglast bool) (idea Idea) {
 	return NewIdeaFromFilename(path.Base(filepath), loglast)
 }
 
 func NewIdeaFromFilename(filename string, loglast bool) (idea Idea) {
+	idea, err := ParseFilename(filename, loglast)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return idea
+}
+
+// ParseFilename parses the idea information from the filename
+func ParseFilename(filename string, loglast bool) (idea Idea, err error) {
 	idea.Filename = filename
 
 	ext := path.Ext(filename)
 	idea.Ext = ext
-	var err error
 	idea.Kind, err = GetKind(ext)
 	if err != nil {
-		log.Fatal(err)
+		return idea, err
 	}
 
 	base := strings.TrimSuffix(filename, path.Ext(filename))
 	split := strings.Split(base, ",")
 	if len(split) < 5 { // must have at minimum: ConsumedPrefix, Id, Created, Edited,and a Tag
-		log.Fatalf("bad filename at %v", filename)
+		return idea, fmt.Errorf("bad filename at %v", filename)
 	}
 
 	// get consumption prefix
@@ -155,24 +219,24 @@ func NewIdeaFromFilename(filename string, loglast bool) (idea Idea) {
 		id, err = ParseIDNoLogLast(split[1])
 	}
 	if err != nil {
-		log.Fatal(err)
+		return idea, fmt.Errorf("bad id at %v: %v", filename, err)
 	}
 	idea.Id = id
 
 	// get creation date
 	created, err := cmn.ParseYYYYdMMdDD(split[2])
 	if err != nil {
-		log.Fatalf("bad created date file format at %v: %v", filename, err)
+		return idea, fmt.Errorf("bad created date file format at %v: %v", filename, err)
 	}
 	idea.Created = created
 
 	// get edit date
 	if !strings.HasPrefix(split[3], "e") {
-		log.Fatalf("bad edit date file format at %v", filename)
+		return idea, fmt.Errorf("bad edit date file format at %v", filename)
 	}
 	edited, err := cmn.ParseYYYYdMMdDD(strings.TrimPrefix(split[3], "e"))
 	if err != nil {
-		log.Fatalf("bad created date file format at %v: %v", filename, err)
+		return idea, fmt.Errorf("bad edit date file format at %v: %v", filename, err)
 	}
 	idea.Edited = edited
 
@@ -190,25 +254,29 @@ func NewIdeaFromFilename(filename string, loglast bool) (idea Idea) {
 	}
 
 	// get any consumes id(s)
-	for ; ; ri++ {
+	for ; ri < len(split); ri++ {
 		if !rxConsumedId.MatchString(split[ri]) {
 			break
 		}
 		// special case to not log so don't use ParseID
 		id, err := strconv.Atoi(strings.TrimPrefix(split[ri], "c"))
 		if err != nil {
-			log.Fatal(err)
+			return idea, err
 		}
 		idea.ConsumesIds = append(idea.ConsumesIds, uint32(id))
 	}
 
 	// get tag(s)
 	if ri == len(split) {
-		log.Fatalf("no tags on file: %v", filename)
+		return idea, fmt.Errorf("no tags on file: %v", filename)
 	}
 	for ; ri < len(split); ri++ {
-		idea.Tags = append(idea.Tags, ParseTagFromString(split[ri])...)
+		tags, err := ParseTagFromStringErr(split[ri])
+		if err != nil {
+			return idea, fmt.Errorf("bad tag %v at %v: %v", split[ri], filename, err)
+		}
+		idea.Tags = append(idea.Tags, tags...)
 	}
 
-	return idea
+	return idea, nil
 }
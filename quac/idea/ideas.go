@@ -135,6 +135,15 @@ func (ideas Ideas) WithImage() (subset Ideas) {
 	return subset
 }
 
+func (ideas Ideas) WithAudio() (subset Ideas) {
+	for _, idea := range ideas {
+		if idea.IsAudio() {
+			subset = append(subset, idea)
+		}
+	}
+	return subset
+}
+
 func (ideas Ideas) UniqueTags() []Tag {
 	tags := make(map[string]Tag)
 	for _, idea := range ideas {
@@ -0,0 +1,104 @@
+package idea
+
+import "sort"
+
+// Lineage is an index of the consumption relationships between ideas, it can
+// be walked backwards (ancestors) or forwards (descendants)
+type Lineage struct {
+	byID     map[uint32]Idea
+	children map[uint32][]uint32
+}
+
+// NewLineage creates a new Lineage object from the provided ideas
+func NewLineage(ideas Ideas) Lineage {
+	lin := Lineage{
+		byID:     make(map[uint32]Idea),
+		children: make(map[uint32][]uint32),
+	}
+	for _, idea := range ideas {
+		lin.byID[idea.Id] = idea
+	}
+	for _, idea := range ideas {
+		for _, parent := range lin.Parents(idea.Id) {
+			lin.children[parent] = append(lin.children[parent], idea.Id)
+		}
+	}
+	for parent := range lin.children {
+		ids := lin.children[parent]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return lin
+}
+
+// Get retrieves an idea from the lineage by id
+func (lin Lineage) Get(id uint32) (idea Idea, found bool) {
+	idea, found = lin.byID[id]
+	return idea, found
+}
+
+// Parents returns the ideas directly consumed by the idea at id. Consuming
+// ideas inherit the consumes ids of what they consume, so any consumes id which
+// is already reached through another consumes id is not a direct parent.
+func (lin Lineage) Parents(id uint32) (parents []uint32) {
+	idea, found := lin.byID[id]
+	if !found {
+		return parents
+	}
+	for _, cid := range idea.ConsumesIds {
+		indirect := false
+		for _, cid2 := range idea.ConsumesIds {
+			if cid2 == cid {
+				continue
+			}
+			if other, found := lin.byID[cid2]; found && other.consumes(cid) {
+				indirect = true
+				break
+			}
+		}
+		if !indirect {
+			parents = append(parents, cid)
+		}
+	}
+	return parents
+}
+
+// Children returns the ideas which directly consume the idea at id
+func (lin Lineage) Children(id uint32) []uint32 {
+	return lin.children[id]
+}
+
+// Ancestors returns the ids of every idea which was consumed (at any depth)
+// to create the idea at id
+func (lin Lineage) Ancestors(id uint32) map[uint32]bool {
+	return lin.walk(id, lin.Parents)
+}
+
+// Descendants returns the ids of every idea which consumes (at any depth)
+// the idea at id
+func (lin Lineage) Descendants(id uint32) map[uint32]bool {
+	return lin.walk(id, lin.Children)
+}
+
+func (lin Lineage) walk(id uint32, next func(uint32) []uint32) map[uint32]bool {
+	visited := make(map[uint32]bool)
+	queue := next(id)
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if visited[cur] || cur == id {
+			continue
+		}
+		visited[cur] = true
+		queue = append(queue, next(cur)...)
+	}
+	return visited
+}
+
+func (idea Idea) consumes(id uint32) bool {
+	for _, cid := range idea.ConsumesIds {
+		if cid == id {
+			return true
+		}
+	}
+	return false
+}
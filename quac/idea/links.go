@@ -0,0 +1,44 @@
+package idea
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// links to other ideas are written within the content as [[123456]]
+var rxLink = regexp.MustCompile(`\[\[(\d{1,6})\]\]`)
+
+// ParseLinks returns the unique ids of all the links within the content in
+// the order which they first appear
+func ParseLinks(content []byte) (ids []uint32) {
+	for _, match := range rxLink.FindAllSubmatch(content, -1) {
+		id, err := strconv.Atoi(string(match[1]))
+		if err != nil {
+			continue
+		}
+		if !containsId(ids, uint32(id)) {
+			ids = append(ids, uint32(id))
+		}
+	}
+	return ids
+}
+
+// GetLinks returns the ids of all the ideas linked to within a text idea
+func (idea Idea) GetLinks() []uint32 {
+	if !idea.IsText() {
+		return []uint32{}
+	}
+	return ParseLinks(idea.GetContent())
+}
+
+// ReplaceLinks replaces every link within the content with the output of the
+// replace function which is provided the linked id and the original link text
+func ReplaceLinks(content string, replace func(id uint32, linkText string) string) string {
+	return rxLink.ReplaceAllStringFunc(content, func(linkText string) string {
+		id, err := strconv.Atoi(rxLink.FindStringSubmatch(linkText)[1])
+		if err != nil {
+			return linkText
+		}
+		return replace(uint32(id), linkText)
+	})
+}
@@ -0,0 +1,62 @@
+package idea
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+var (
+	// OCR drafts (draft transcriptions of image ideas) are stored within
+	// OCRDir as text files named by the sha256 checksum of the image, so a
+	// draft follows its image through renumbering, syncs and imports
+	OCRDir string
+
+	// whether CONTAINS tags search the OCR drafts of image ideas
+	SearchOCRDrafts bool
+)
+
+// OCRDraftPath returns the path of the OCR draft of an image by its checksum
+func OCRDraftPath(checksum string) string {
+	return path.Join(OCRDir, checksum+".txt")
+}
+
+// OCRDraftPath returns the path of the OCR draft of the image idea
+func (idea Idea) OCRDraftPath() (string, error) {
+	bz, err := ioutil.ReadFile(idea.Path())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bz)
+	return OCRDraftPath(hex.EncodeToString(sum[:])), nil
+}
+
+// GetOCRDraft returns the OCR draft of the idea if one has been generated
+func (idea Idea) GetOCRDraft() (draft string, found bool) {
+	draftPath, err := idea.OCRDraftPath()
+	if err != nil {
+		return "", false
+	}
+	bz, err := ioutil.ReadFile(draftPath)
+	if err != nil {
+		return "", false
+	}
+	return string(bz), true
+}
+
+// RemoveOCRDraft removes the OCR draft of the image idea if there is one
+func (idea Idea) RemoveOCRDraft() error {
+	if !idea.IsImage() {
+		return nil
+	}
+	draftPath, err := idea.OCRDraftPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(draftPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package idea
+
+import (
+	"fmt"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+)
+
+// relation types, relations are directional: "from <type> to"
+const (
+	RelSupports     = "supports"
+	RelContradicts  = "contradicts"
+	RelExtends      = "extends"
+	RelPrerequisite = "prerequisite"
+)
+
+var RelationTypes = []string{RelSupports, RelContradicts, RelExtends, RelPrerequisite}
+
+// Relation is a typed and directional relationship between two ideas.
+// Within the relations file each relation is stored on its own line in the
+// format: 123456,supports,654321
+type Relation struct {
+	From uint32
+	Type string
+	To   uint32
+}
+
+type Relations []Relation
+
+// NewRelation creates a new Relation object
+func NewRelation(from uint32, relType string, to uint32) (Relation, error) {
+	if err := ValidateRelationType(relType); err != nil {
+		return Relation{}, err
+	}
+	if from == to {
+		return Relation{}, fmt.Errorf("idea %v cannot relate to itself", IdStr(from))
+	}
+	return Relation{from, relType, to}, nil
+}
+
+func ValidateRelationType(relType string) error {
+	for _, rt := range RelationTypes {
+		if rt == relType {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown relation type %v, must be one of %v",
+		relType, strings.Join(RelationTypes, ", "))
+}
+
+func ParseRelation(line string) (Relation, error) {
+	split := strings.Split(line, ",")
+	if len(split) != 3 {
+		return Relation{}, fmt.Errorf("bad relation: %v", line)
+	}
+	from, err := ParseIDNoLogLast(split[0])
+	if err != nil {
+		return Relation{}, fmt.Errorf("bad relation: %v, error: %v", line, err)
+	}
+	to, err := ParseIDNoLogLast(split[2])
+	if err != nil {
+		return Relation{}, fmt.Errorf("bad relation: %v, error: %v", line, err)
+	}
+	return NewRelation(from, split[1], to)
+}
+
+func (r Relation) String() string {
+	return IdStr(r.From) + "," + r.Type + "," + IdStr(r.To)
+}
+
+// GetAllRelations reads all the relations from the relations file
+func GetAllRelations() (rels Relations) {
+	lines, err := cmn.ReadLines(RelationsFile)
+	if err != nil {
+		panic(fmt.Sprintf("error reading relations, error: %v", err))
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		rel, err := ParseRelation(line)
+		if err != nil {
+			panic(err)
+		}
+		rels = append(rels, rel)
+	}
+	return rels
+}
+
+// WriteRelations overwrites the relations file
+func WriteRelations(rels Relations) {
+	lines := make([]string, len(rels))
+	for i, rel := range rels {
+		lines[i] = rel.String()
+	}
+	err := cmn.WriteLines(lines, RelationsFile)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (rels Relations) Has(rel Relation) bool {
+	for _, r := range rels {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// relations in which the idea at id is either the from or the to
+func (rels Relations) Involving(id uint32) (subset Relations) {
+	for _, rel := range rels {
+		if rel.From == id || rel.To == id {
+			subset = append(subset, rel)
+		}
+	}
+	return subset
+}
+
+// relations in which either the from or the to idea is included in the ideas
+func (rels Relations) InvolvingAny(ideas Ideas) (subset Relations) {
+	ids := make(map[uint32]bool)
+	for _, idea := range ideas {
+		ids[idea.Id] = true
+	}
+	for _, rel := range rels {
+		if ids[rel.From] || ids[rel.To] {
+			subset = append(subset, rel)
+		}
+	}
+	return subset
+}
+
+// all the relations excluding those which match the from and to ids, as well
+// as the relation type if one is provided
+func (rels Relations) Without(from uint32, relType string, to uint32) (subset Relations) {
+	for _, rel := range rels {
+		if rel.From == from && rel.To == to &&
+			(relType == "" || rel.Type == relType) {
+			continue
+		}
+		subset = append(subset, rel)
+	}
+	return subset
+}
@@ -240,7 +240,10 @@ func NewTagContains(keyword, containsWhat string) ([]Tag, error) {
 }
 
 func (t TagContains) Includes(idea Idea) bool {
-	bz := idea.GetContent()
+	bz, ok := idea.searchableContent()
+	if !ok {
+		return false
+	}
 	fnToLower := func(in string) string { return in }
 	if t.CaseInsensitive {
 		fnToLower = strings.ToLower
@@ -328,10 +331,100 @@ func (t TagDates) Includes(idea Idea) bool {
 	return false
 }
 
+// ------------------------------------------
+type TagLineage struct {
+	TagBase
+	ids map[uint32]bool
+}
+
+var _ Tag = TagLineage{}
+var (
+	DescendsFromKeyword = "DESCENDS-FROM"
+	AncestorOfKeyword   = "ANCESTOR-OF"
+)
+
+func init() {
+	st.registerTags(NewTagLineage,
+		DescendsFromKeyword, AncestorOfKeyword)
+}
+
+// the ids in the lineage are determined once upon creation of the tag
+func NewTagLineage(keyword, idStr string) ([]Tag, error) {
+	id, err := ParseIDNoLogLast(idStr)
+	if err != nil {
+		return []Tag{}, err
+	}
+	lin := NewLineage(GetAllIdeas())
+	var ids map[uint32]bool
+	switch keyword {
+	case DescendsFromKeyword:
+		ids = lin.Descendants(id)
+	case AncestorOfKeyword:
+		ids = lin.Ancestors(id)
+	}
+	return []Tag{TagLineage{NewTagBase(keyword, idStr), ids}}, nil
+}
+
+func (t TagLineage) Includes(idea Idea) bool {
+	return t.ids[idea.Id]
+}
+
+// ------------------------------------------
+type TagRelation struct {
+	TagBase
+	relType string
+	toId    uint32
+	rels    Relations
+}
+
+var _ Tag = TagRelation{}
+var RelationKeyword = "REL"
+
+func init() { st.registerTags(NewTagRelation, RelationKeyword) }
+
+// value is in the format type:id, for example "REL=supports:123456" includes
+// all ideas which support the idea 123456. If the id is omitted all ideas
+// with the relation type to any other idea are included.
+func NewTagRelation(keyword, value string) ([]Tag, error) {
+	split := strings.SplitN(value, ":", 2)
+	relType := split[0]
+	if err := ValidateRelationType(relType); err != nil {
+		return []Tag{}, err
+	}
+	var toId uint32
+	if len(split) == 2 {
+		var err error
+		toId, err = ParseIDNoLogLast(split[1])
+		if err != nil {
+			return []Tag{}, err
+		}
+	}
+	return []Tag{TagRelation{NewTagBase(keyword, value), relType, toId, GetAllRelations()}}, nil
+}
+
+func (t TagRelation) Includes(idea Idea) bool {
+	for _, rel := range t.rels {
+		if rel.From == idea.Id && rel.Type == t.relType &&
+			(t.toId == 0 || rel.To == t.toId) {
+			return true
+		}
+	}
+	return false
+}
+
 //_______________________________________________________
 
 // NOTE all tag types must be registered within this function
 func ParseTagFromString(in string) []Tag {
+	ts, err := ParseTagFromStringErr(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ts
+}
+
+// ParseTagFromStringErr parses the tag(s) from the string returning any error
+func ParseTagFromStringErr(in string) ([]Tag, error) {
 	keyword, value := in, ""
 	splt := strings.Split(in, "=")
 	if len(splt) == 2 {
@@ -341,11 +434,29 @@ func ParseTagFromString(in string) []Tag {
 	if !found {
 		fn = NewTagReg
 	}
-	ts, err := fn(keyword, value)
-	if err != nil {
-		log.Fatal(err)
+	return fn(keyword, value)
+}
+
+// SanitizeTag converts arbitrary text (such as a tag from another
+// application) into a string usable as a tag within an idea filename
+func SanitizeTag(in string) string {
+	out := strings.Map(func(ch rune) rune {
+		switch ch {
+		case ',', ' ', '\t', '\n', '/', '\\', '[', ']':
+			return '-'
+		}
+		return ch
+	}, strings.TrimSpace(in))
+
+	// only allow a single name=value split
+	split := strings.SplitN(out, "=", 2)
+	if len(split) == 2 {
+		out = split[0] + "=" + strings.Replace(split[1], "=", "-", -1)
 	}
-	return ts
+	for strings.Contains(out, "--") {
+		out = strings.Replace(out, "--", "-", -1)
+	}
+	return strings.Trim(out, "-")
 }
 
 func ParseFirstTagFromString(in string) Tag {
@@ -373,6 +484,28 @@ func ConcatAllContentFromTags(tags []Tag) (content []byte, found bool) {
 
 // parse clumped tags seperated by spaces or commas
 func ParseClumpedTags(clumpedTags string) []Tag {
+	return ParseStringTags(SplitClumpedTags(clumpedTags))
+}
+
+// ParseClumpedTagsErr parses the clumped tags returning any error
+func ParseClumpedTagsErr(clumpedTags string) ([]Tag, error) {
+	var out []Tag
+	for _, s := range SplitClumpedTags(clumpedTags) {
+		trim := strings.TrimSpace(s)
+		if len(trim) == 0 {
+			continue
+		}
+		ts, err := ParseTagFromStringErr(trim)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ts...)
+	}
+	return out, nil
+}
+
+// split clumped tags into the string of each tag
+func SplitClumpedTags(clumpedTags string) []string {
 	trim := strings.TrimPrefix(clumpedTags, ",")
 	trim = strings.TrimSuffix(trim, ",")
 	trim = strings.TrimSuffix(trim, " ")
@@ -401,7 +534,7 @@ func ParseClumpedTags(clumpedTags string) []Tag {
 		split = append(split, collecting)
 	}
 
-	return ParseStringTags(split)
+	return split
 }
 
 func ParseStringTags(strTags []string) []Tag {
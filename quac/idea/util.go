@@ -26,9 +26,9 @@ const (
 )
 
 var (
-	IdeasDir, ConfigFile, LastIdFile string
-	zeroDate                         time.Time
-	rxConsumedId                     = regexp.MustCompile(`[c]\d{6,6}`)
+	IdeasDir, ConfigFile, LastIdFile, RelationsFile string
+	zeroDate                                        time.Time
+	rxConsumedId                                    = regexp.MustCompile(`[c]\d{6,6}`)
 )
 
 func TodayDate() time.Time {
@@ -56,6 +56,43 @@ func GetKind(ext string) (int, error) {
 	return 0, nil
 }
 
+// human readable name of the kind of idea
+func KindName(kind int) string {
+	switch kind {
+	case KindText:
+		return "text"
+	case KindImage:
+		return "image"
+	case KindAudio:
+		return "audio"
+	case KindEnText:
+		return "encrypted"
+	}
+	return "unknown"
+}
+
+// human readable name of the cycle of an idea
+func CycleName(cycle int) string {
+	switch cycle {
+	case CycleAlive:
+		return "alive"
+	case CycleConsumed:
+		return "consumed"
+	case CycleZombie:
+		return "zombie"
+	}
+	return "unknown"
+}
+
+func ParseCycleName(name string) (int, error) {
+	for _, cycle := range []int{CycleAlive, CycleConsumed, CycleZombie} {
+		if CycleName(cycle) == name {
+			return cycle, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cycle: %v", name)
+}
+
 func (idea Idea) Path() string {
 	return path.Join(IdeasDir, idea.Filename)
 }
@@ -0,0 +1,106 @@
+package quac
+
+import (
+	"io/ioutil"
+	"mime"
+	"os"
+	"strings"
+	"time"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// tags of ideas imported from other applications, each string is sanitized
+// into a valid tag and strings which can not be made valid are skipped. If no
+// tags remain the idea is tagged as UNTAGGED.
+func importTags(tagStrs []string) (tags []idea.Tag) {
+	var holder idea.Idea
+	for _, tagStr := range tagStrs {
+		tagStr = idea.SanitizeTag(strings.TrimPrefix(tagStr, "#"))
+		if tagStr == "" {
+			continue
+		}
+		split := strings.SplitN(tagStr, "=", 2)
+		value := ""
+		if len(split) == 2 {
+			value = split[1]
+		}
+		newTags, err := idea.NewTagReg(split[0], value)
+		if err != nil {
+			// reserved tag names are upper case
+			newTags, err = idea.NewTagReg(strings.ToLower(split[0]), value)
+			if err != nil {
+				continue
+			}
+		}
+		for _, tag := range newTags {
+			holder.AddTags([]idea.Tag{tag})
+		}
+	}
+	if len(holder.Tags) == 0 {
+		return []idea.Tag{idea.MustNewTagReg("UNTAGGED", "")}
+	}
+	return holder.Tags
+}
+
+func truncateToDate(t time.Time) time.Time {
+	date, _ := cmn.ParseYYYYdMMdDD(t.Format(cmn.LayoutYYYYdMMdDD))
+	return date
+}
+
+// file extensions of the mime types of files which can be kept as ideas
+var mimeExts = map[string]string{
+	"image/jpeg":   ".jpg",
+	"image/jpg":    ".jpg",
+	"image/png":    ".png",
+	"image/tiff":   ".tiff",
+	"audio/wav":    ".wav",
+	"audio/x-wav":  ".wav",
+	"audio/wave":   ".wav",
+	"audio/mpeg":   ".mp3",
+	"audio/mp3":    ".mp3",
+	"audio/x-mpeg": ".mp3",
+}
+
+// MimeExtension returns the idea file extension for a mime type, found is
+// false if the mime type is not of an image or audio kind of idea
+func MimeExtension(mimeType string) (ext string, found bool) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType = strings.TrimSpace(mimeType)
+	}
+	ext, found = mimeExts[strings.ToLower(mediaType)]
+	return ext, found
+}
+
+// write an imported image or audio file as a new idea. A consumed idea is
+// consumed on the consumed date, otherwise the consumed date is ignored.
+func importFile(data []byte, ext string, cycle int, created,
+	consumed time.Time, tags []idea.Tag) (idear idea.Idea, err error) {
+
+	kind, err := idea.GetKind(ext)
+	if err != nil {
+		return idear, err
+	}
+	idear = idea.Idea{
+		Cycle:       cycle,
+		Id:          idea.GetNextID(),
+		ConsumesIds: []uint32{},
+		Kind:        kind,
+		Ext:         ext,
+		Created:     created,
+		Edited:      created,
+		Tags:        tags,
+	}
+	if cycle != CycleAlive {
+		idear.Consumed = consumed
+	}
+	(&idear).UpdateFilename()
+	err = ioutil.WriteFile(idear.Path(), data, os.ModePerm)
+	if err != nil {
+		return idear, err
+	}
+	idea.IncrementID()
+	return idear, nil
+}
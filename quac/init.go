@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	cmn "github.com/rigelrozanski/common"
 	"github.com/rigelrozanski/thranch/quac/idea"
@@ -12,14 +14,20 @@ import (
 
 // directory name where boards are stored in repo
 var (
-	QuDir              string
-	DefaultScanDir     string
-	DeleteWhenScanning bool = false
-	TrashCanDir        string
-	QuFile             string
-	LogFile            string
-	WorkingFnsFile     string
-	WorkingContentFile string
+	QuDir               string
+	DefaultScanDir      string
+	DeleteWhenScanning  bool = false
+	TrashCanDir         string
+	QuFile              string
+	LogFile             string
+	WorkingFnsFile      string
+	WorkingContentFile  string
+	WorkingSplitFile    string
+	WorkingViewFile     string
+	WorkingSnapshotFile string
+	ClippingsLedger     string
+	BackupDir           string // default backup destination
+	BackupDestFile      string // records the last backup destination used
 )
 
 // load config and set global file directories
@@ -42,6 +50,57 @@ func Initialize(thranchConfigPath string) {
 			if dws == "true" || dws == "TRUE" || dws == "True" {
 				DeleteWhenScanning = true
 			}
+		case strings.HasPrefix(line, "backup-dir="):
+			BackupDir = os.ExpandEnv(strings.TrimPrefix(line, "backup-dir="))
+		case strings.HasPrefix(line, "include-encrypted="):
+			ie := strings.ToLower(strings.TrimPrefix(line, "include-encrypted="))
+			idea.IncludeEncrypted = ie == "true"
+		case strings.HasPrefix(line, "vault="):
+			VaultMode = strings.ToLower(strings.TrimPrefix(line, "vault=")) == "true"
+		case strings.HasPrefix(line, "vault-disk-unlock="):
+			VaultDiskUnlock = strings.ToLower(strings.TrimPrefix(line, "vault-disk-unlock=")) == "true"
+		case strings.HasPrefix(line, "vault-timeout="):
+			VaultTimeout, err = time.ParseDuration(strings.TrimPrefix(line, "vault-timeout="))
+			if err != nil {
+				panic(fmt.Sprintf("bad vault-timeout in thranch config: %v", line))
+			}
+		case strings.HasPrefix(line, "editor="):
+			EditorCmd = strings.TrimPrefix(line, "editor=")
+		case strings.HasPrefix(line, "working-view="):
+			WorkingViewMode = strings.TrimPrefix(line, "working-view=")
+			if WorkingViewMode != "single" && WorkingViewMode != "split" {
+				panic(fmt.Sprintf("bad working-view in thranch config: %v", line))
+			}
+		case strings.HasPrefix(line, "confirm-working="):
+			WorkingConfirm = strings.ToLower(strings.TrimPrefix(line, "confirm-working=")) != "false"
+		case strings.HasPrefix(line, "image-renderer="):
+			ImageRenderer = strings.TrimPrefix(line, "image-renderer=")
+		case strings.HasPrefix(line, "audio-player="):
+			AudioPlayer = strings.TrimPrefix(line, "audio-player=")
+		case strings.HasPrefix(line, "audio-recorder="):
+			AudioRecorder = strings.TrimPrefix(line, "audio-recorder=")
+		case strings.HasPrefix(line, "ocr="):
+			OCRCommand = strings.TrimPrefix(line, "ocr=")
+		case strings.HasPrefix(line, "ocr-lang="):
+			OCRLang = strings.TrimPrefix(line, "ocr-lang=")
+		case strings.HasPrefix(line, "ocr-search="):
+			idea.SearchOCRDrafts = strings.ToLower(strings.TrimPrefix(line, "ocr-search=")) == "true"
+		case strings.HasPrefix(line, "device="):
+			idea.DeviceName = strings.TrimPrefix(line, "device=")
+			if strings.Contains(idea.DeviceName, ",") {
+				panic("device name in thranch config cannot contain commas")
+			}
+		case strings.HasPrefix(line, "id-range="):
+			idea.DeviceStart, idea.DeviceEnd, err = idea.ParseDeviceRange(strings.TrimPrefix(line, "id-range="))
+			if err != nil {
+				panic(err)
+			}
+		case strings.HasPrefix(line, "id-block-size="):
+			size, err := strconv.Atoi(strings.TrimPrefix(line, "id-block-size="))
+			if err != nil || size < 1 {
+				panic(fmt.Sprintf("bad id-block-size in thranch config: %v", line))
+			}
+			idea.IdBlockSize = uint32(size)
 		}
 	}
 
@@ -52,13 +111,43 @@ func Initialize(thranchConfigPath string) {
 	idea.ConfigFile = path.Join(QuDir, "config")
 	WorkingFnsFile = path.Join(QuDir, "working_files")
 	WorkingContentFile = path.Join(QuDir, "working_content")
+	WorkingSplitFile = path.Join(QuDir, "working_split")
+	WorkingViewFile = path.Join(QuDir, "working_view")
+	WorkingSnapshotFile = path.Join(QuDir, "working_snapshot")
+	ClippingsLedger = path.Join(QuDir, "clippings_imported")
+	idea.OCRDir = path.Join(QuDir, "ocr")
+	BackupDestFile = path.Join(QuDir, "backup_dest")
 	idea.LastIdFile = path.Join(QuDir, "last")
+	idea.RelationsFile = path.Join(QuDir, "relations")
+	idea.IdBlocksFile = path.Join(QuDir, "id_blocks")
+
+	// within vault mode ideas and content are within the unlocked directory
+	if VaultMode {
+		setVaultPaths()
+		idea.IdeasDir = path.Join(VaultUnlockedDir, "ideas")
+		TrashCanDir = path.Join(VaultUnlockedDir, "trash")
+		idea.OCRDir = path.Join(VaultUnlockedDir, "ocr")
+		QuFile = path.Join(VaultUnlockedDir, "qu")
+		WorkingFnsFile = path.Join(VaultUnlockedDir, "working_files")
+		WorkingContentFile = path.Join(VaultUnlockedDir, "working_content")
+		WorkingSplitFile = path.Join(VaultUnlockedDir, "working_split")
+		WorkingViewFile = path.Join(VaultUnlockedDir, "working_view")
+		WorkingSnapshotFile = path.Join(VaultUnlockedDir, "working_snapshot")
+		idea.RelationsFile = path.Join(VaultUnlockedDir, "relations")
+		initVaultSession()
+	}
 
 	EnsureBasics()
 
 	IdeasDir = idea.IdeasDir
 	ConfigFile = idea.ConfigFile
 	LastIdFile = idea.LastIdFile
+	RelationsFile = idea.RelationsFile
+	IdBlocksFile = idea.IdBlocksFile
+
+	if idea.DeviceStart != 0 && idea.DeviceName == "" {
+		panic("an id-range in thranch config requires a device name (device=<name>)")
+	}
 }
 
 func EnsureBasics() {
@@ -66,13 +155,34 @@ func EnsureBasics() {
 		panic("directory specified in thranch config does not exist")
 	}
 
-	_ = os.Mkdir(idea.IdeasDir, os.ModePerm)
-	_ = os.Mkdir(TrashCanDir, os.ModePerm)
+	// nothing is created within a locked vault
+	if !VaultLocked {
+		_ = os.Mkdir(idea.IdeasDir, os.ModePerm)
+		_ = os.Mkdir(TrashCanDir, os.ModePerm)
 
-	if !cmn.FileExists(QuFile) {
-		err := cmn.CreateEmptyFile(QuFile)
-		if err != nil {
-			panic(err)
+		if !cmn.FileExists(QuFile) {
+			err := cmn.CreateEmptyFile(QuFile)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if !cmn.FileExists(WorkingFnsFile) {
+			err := cmn.CreateEmptyFile(WorkingFnsFile)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if !cmn.FileExists(WorkingContentFile) {
+			err := cmn.CreateEmptyFile(WorkingContentFile)
+			if err != nil {
+				panic(err)
+			}
+		}
+		if !cmn.FileExists(idea.RelationsFile) {
+			err := cmn.CreateEmptyFile(idea.RelationsFile)
+			if err != nil {
+				panic(err)
+			}
 		}
 	}
 	if !cmn.FileExists(LogFile) {
@@ -81,14 +191,8 @@ func EnsureBasics() {
 			panic(err)
 		}
 	}
-	if !cmn.FileExists(WorkingFnsFile) {
-		err := cmn.CreateEmptyFile(WorkingFnsFile)
-		if err != nil {
-			panic(err)
-		}
-	}
-	if !cmn.FileExists(WorkingContentFile) {
-		err := cmn.CreateEmptyFile(WorkingContentFile)
+	if !cmn.FileExists(ClippingsLedger) {
+		err := cmn.CreateEmptyFile(ClippingsLedger)
 		if err != nil {
 			panic(err)
 		}
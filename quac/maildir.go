@@ -0,0 +1,235 @@
+package quac
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// maildir flag marking a message as seen
+const maildirSeenFlag = "S"
+
+// a part of an email message after decoding
+type mailPart struct {
+	mediaType string
+	filename  string
+	data      []byte
+}
+
+// ImportMaildir creates an idea from each unseen message within the maildir.
+// The subject of each message is parsed as clumped tags, to which extraTags
+// (also clumped) are added. The plain text body becomes a text idea and image
+// or audio attachments become ideas which are consumed by the text idea, if
+// the message has no body the attachments are left alive with the tags of the
+// subject. Each message is marked as seen once it has been imported.
+func ImportMaildir(dir, extraTags string) (imported idea.Ideas, err error) {
+	for _, msgPath := range unseenMaildirMessages(dir) {
+		msgIdeas, err := importMailMessage(msgPath, extraTags)
+		if err != nil {
+			return imported, fmt.Errorf("%v: %v", msgPath, err)
+		}
+		imported = append(imported, msgIdeas...)
+		if err := markMaildirSeen(dir, msgPath); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// messages within new/ and those within cur/ without the seen flag
+func unseenMaildirMessages(dir string) (msgPaths []string) {
+	for _, sub := range []string{"new", "cur"} {
+		fis, err := ioutil.ReadDir(path.Join(dir, sub))
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			if sub == "cur" && strings.Contains(maildirFlags(fi.Name()), maildirSeenFlag) {
+				continue
+			}
+			msgPaths = append(msgPaths, path.Join(dir, sub, fi.Name()))
+		}
+	}
+	return msgPaths
+}
+
+// the flags of a maildir message from its filename (unique:2,flags)
+func maildirFlags(filename string) string {
+	i := strings.LastIndex(filename, ":2,")
+	if i < 0 {
+		return ""
+	}
+	return filename[i+3:]
+}
+
+// move the message into cur/ adding the seen flag, flags remain sorted
+func markMaildirSeen(dir, msgPath string) error {
+	filename := path.Base(msgPath)
+	unique, flags := filename, ""
+	if i := strings.LastIndex(filename, ":2,"); i >= 0 {
+		unique, flags = filename[:i], filename[i+3:]
+	}
+	split := strings.Split(flags+maildirSeenFlag, "")
+	sort.Strings(split)
+	return os.Rename(msgPath, path.Join(dir, "cur", unique+":2,"+strings.Join(split, "")))
+}
+
+func importMailMessage(msgPath, extraTags string) (imported idea.Ideas, err error) {
+	f, err := os.Open(msgPath)
+	if err != nil {
+		return imported, err
+	}
+	defer f.Close()
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return imported, err
+	}
+
+	created := idea.TodayDate()
+	if date, err := msg.Header.Date(); err == nil {
+		created = truncateToDate(date.Local())
+	}
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	for _, prefix := range []string{"re:", "fwd:", "fw:"} {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), prefix) {
+			subject = strings.TrimSpace(subject)[len(prefix):]
+		}
+	}
+	tags := importTags(append(idea.SplitClumpedTags(subject), idea.SplitClumpedTags(extraTags)...))
+
+	parts, err := readMailParts(msg.Header.Get("Content-Type"),
+		msg.Header.Get("Content-Transfer-Encoding"), "", msg.Body)
+	if err != nil {
+		return imported, err
+	}
+	var plain, html []string
+	var attachments []mailPart
+	for _, part := range parts {
+		switch {
+		case part.mediaType == "text/plain" && part.filename == "":
+			plain = append(plain, string(part.data))
+		case part.mediaType == "text/html" && part.filename == "":
+			html = append(html, HTMLToText(string(part.data), func(string) string { return "" }))
+		default:
+			attachments = append(attachments, part)
+		}
+	}
+	body := strings.TrimSpace(strings.Join(plain, "\n\n"))
+	if body == "" {
+		body = strings.TrimSpace(strings.Join(html, "\n\n"))
+	}
+
+	cycle := CycleConsumed
+	if body == "" {
+		cycle = CycleAlive
+	}
+	var consumesIds []uint32
+	for _, att := range attachments {
+		ext, found := MimeExtension(att.mediaType)
+		if !found {
+			ext, found = MimeExtension(mime.TypeByExtension(path.Ext(att.filename)))
+		}
+		if !found {
+			body += fmt.Sprintf("\n\n[attachment not imported: %v (%v)]", att.filename, att.mediaType)
+			continue
+		}
+		attIdea, err := importFile(att.data, ext, cycle, created, created, tags)
+		if err != nil {
+			return imported, err
+		}
+		consumesIds = append(consumesIds, attIdea.Id)
+		imported = append(imported, attIdea)
+		if cycle == CycleConsumed {
+			body += "\n\n[[" + idea.IdStr(attIdea.Id) + "]]"
+		}
+	}
+	if strings.TrimSpace(body) == "" {
+		return imported, nil
+	}
+
+	textIdea := idea.Idea{
+		Cycle:       CycleAlive,
+		Id:          idea.GetNextID(),
+		ConsumesIds: []uint32{},
+		Kind:        KindText,
+		Created:     created,
+		Edited:      created,
+		Tags:        tags,
+	}
+	if cycle == CycleConsumed {
+		textIdea.ConsumesIds = consumesIds
+	}
+	(&textIdea).UpdateFilename()
+	WriteIdea(textIdea.Filename, strings.TrimSpace(body)+"\n")
+	idea.IncrementID()
+	return append(imported, textIdea), nil
+}
+
+// decode the body of a message or part, recursing into multipart content
+func readMailParts(contentType, encoding, disposition string, body io.Reader) (parts []mailPart, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	mediaType = strings.ToLower(mediaType)
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return parts, nil
+			} else if err != nil {
+				return parts, err
+			}
+			subParts, err := readMailParts(p.Header.Get("Content-Type"),
+				p.Header.Get("Content-Transfer-Encoding"),
+				p.Header.Get("Content-Disposition"), p)
+			if err != nil {
+				return parts, err
+			}
+			parts = append(parts, subParts...)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return parts, err
+	}
+
+	part := mailPart{mediaType: mediaType, data: data}
+	if _, dparams, err := mime.ParseMediaType(disposition); err == nil {
+		part.filename = dparams["filename"]
+	}
+	if part.filename == "" {
+		part.filename = params["name"]
+	}
+	if strings.HasPrefix(strings.ToLower(disposition), "attachment") && part.filename == "" {
+		part.filename = "attachment"
+	}
+	return []mailPart{part}, nil
+}
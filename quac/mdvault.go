@@ -0,0 +1,365 @@
+package quac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// A markdown vault (such as used by Obsidian or Logseq) is a directory of
+// markdown notes with optional YAML front matter, [[wikilinks]] and #tags.
+
+// front matter keys used when exporting, common alternatives are also read
+const (
+	fmId       = "quac-id"
+	fmTags     = "tags"
+	fmCreated  = "created"
+	fmUpdated  = "updated"
+	fmCycle    = "cycle"
+	fmConsumed = "consumed"
+	fmConsumes = "consumes"
+
+	vaultAttachmentsDir = "attachments"
+)
+
+var (
+	rxInlineTag = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/=-]*[\p{L}_][\p{L}\p{N}_/=-]*)`)
+	rxWikilink  = regexp.MustCompile(`\[\[([^\[\]|#]+)(#[^\[\]|]*)?(\|[^\[\]]*)?\]\]`)
+	rxVaultId   = regexp.MustCompile(`^\d{1,6}$`)
+)
+
+// split the YAML front matter (only a simple subset of YAML is supported:
+// "key: value", "key: [a, b]" and indented "- value" lists) from the body
+func ParseFrontMatter(content string) (fm map[string][]string, body string) {
+	fm = make(map[string][]string)
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != "---" {
+		return fm, content
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return fm, content
+	}
+
+	unquote := func(in string) string {
+		return strings.Trim(strings.TrimSpace(in), `"'`)
+	}
+	key := ""
+	for _, line := range lines[1:end] {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") && key != "" {
+			fm[key] = append(fm[key], unquote(strings.TrimPrefix(trimmed, "- ")))
+			continue
+		}
+		split := strings.SplitN(trimmed, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(split[0]))
+		value := strings.TrimSpace(split[1])
+		fm[key] = []string{}
+		switch {
+		case value == "":
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, v := range strings.Split(value[1:len(value)-1], ",") {
+				if unquote(v) != "" {
+					fm[key] = append(fm[key], unquote(v))
+				}
+			}
+		default:
+			fm[key] = []string{unquote(value)}
+		}
+	}
+	return fm, strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+}
+
+// the first front matter value of any of the keys
+func fmFirst(fm map[string][]string, keys ...string) string {
+	for _, key := range keys {
+		if len(fm[key]) > 0 {
+			return fm[key][0]
+		}
+	}
+	return ""
+}
+
+// parse a date which may be followed by a time (such as 2020-01-02T15:04)
+func parseVaultDate(in string) (time.Time, bool) {
+	if len(in) < len(cmn.LayoutYYYYdMMdDD) {
+		return time.Time{}, false
+	}
+	date, err := cmn.ParseYYYYdMMdDD(in[:len(cmn.LayoutYYYYdMMdDD)])
+	return date, err == nil
+}
+
+// a note read from the vault prior to being written as an idea
+type vaultNote struct {
+	stem     string
+	relPath  string // within the vault, without the extension
+	foreign  uint32 // quac id from the front matter, 0 if none
+	idea     idea.Idea
+	body     string
+	consumes []string
+}
+
+// ImportMarkdownVault creates a text idea for every markdown file within the
+// directory. Front matter and inline #tags become tags, the modification time
+// becomes the created and edited dates (unless provided within the front
+// matter) and [[wikilinks]] between notes become [[id]] links. Links to ids
+// of ideas which weren't exported are left as the plain id. Notes sharing a
+// name within different directories are reported within the warnings, as
+// links to them by the name alone are left as is.
+func ImportMarkdownVault(dir string, preserveIds bool) (imported idea.Ideas, warnings []string, err error) {
+	var notes []*vaultNote
+	err = filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fp != dir && strings.HasPrefix(fi.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.ToLower(path.Ext(fp)) != ".md" {
+			return nil
+		}
+		note, err := readVaultNote(fp, fi)
+		if err != nil {
+			return fmt.Errorf("%v: %v", fp, err)
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		note.relPath = strings.TrimSuffix(filepath.ToSlash(rel), path.Ext(rel))
+		notes = append(notes, note)
+		return nil
+	})
+	if err != nil {
+		return imported, warnings, err
+	}
+
+	// allocate ids, notes exported from a ranch keep track of their id
+	var foreignIds []uint32
+	for _, note := range notes {
+		if note.foreign != 0 {
+			foreignIds = append(foreignIds, note.foreign)
+		}
+	}
+	remap := AllocateIds(foreignIds, preserveIds)
+	byPath := make(map[string]uint32)
+	byStem := make(map[string]uint32)
+	stemPaths := make(map[string][]string)
+	for _, note := range notes {
+		if note.foreign != 0 {
+			note.idea.Id = remap[note.foreign]
+		} else {
+			note.idea.Id = idea.GetNextID()
+			idea.IncrementID()
+		}
+		stem := strings.ToLower(note.stem)
+		byPath[strings.ToLower(note.relPath)] = note.idea.Id
+		byStem[stem] = note.idea.Id
+		stemPaths[stem] = append(stemPaths[stem], note.relPath+".md")
+	}
+	for _, note := range notes {
+		stem := strings.ToLower(note.stem)
+		if paths := stemPaths[stem]; len(paths) > 1 {
+			warnings = append(warnings, fmt.Sprintf("notes %v share the name %v, links to "+
+				"[[%v]] are left as is", strings.Join(paths, ", "), note.stem, note.stem))
+			delete(stemPaths, stem)
+			delete(byStem, stem)
+		}
+	}
+
+	for _, note := range notes {
+		for _, c := range note.consumes {
+			cid, err := idea.ParseIDNoLogLast(c)
+			if err != nil {
+				continue
+			}
+			if newId, found := remap[cid]; found {
+				note.idea.ConsumesIds = append(note.idea.ConsumesIds, newId)
+			}
+		}
+		(&note.idea).UpdateFilename()
+
+		// convert wikilinks to notes within the vault into id links, links to
+		// ids outside of the vault would link to unrelated ideas of this ranch
+		body := rxWikilink.ReplaceAllStringFunc(note.body, func(link string) string {
+			target := strings.ToLower(strings.TrimSuffix(
+				strings.TrimSpace(rxWikilink.FindStringSubmatch(link)[1]), ".md"))
+			id, found := byPath[target]
+			if !found {
+				id, found = byStem[path.Base(target)]
+			}
+			if !found && rxVaultId.MatchString(target) {
+				foreign, _ := strconv.Atoi(target)
+				if id, found = remap[uint32(foreign)]; !found {
+					return idea.IdStr(uint32(foreign))
+				}
+			}
+			if !found {
+				return link
+			}
+			return "[[" + idea.IdStr(id) + "]]"
+		})
+		WriteIdea(note.idea.Filename, body)
+		imported = append(imported, note.idea)
+	}
+	return imported, warnings, nil
+}
+
+func readVaultNote(fp string, fi os.FileInfo) (*vaultNote, error) {
+	bz, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	fm, body := ParseFrontMatter(string(bz))
+	note := &vaultNote{
+		stem:     strings.TrimSuffix(fi.Name(), path.Ext(fi.Name())),
+		body:     body,
+		consumes: fm[fmConsumes],
+	}
+	if id, err := idea.ParseIDNoLogLast(fmFirst(fm, fmId)); err == nil && id != 0 {
+		note.foreign = id
+	}
+
+	// keep the title of notes which were not exported from a ranch
+	if note.foreign == 0 && !strings.HasPrefix(body, "# ") {
+		note.body = "# " + note.stem + "\n\n" + body
+	}
+
+	idear := idea.Idea{
+		Cycle: CycleAlive,
+		Kind:  KindText,
+	}
+	idear.Edited = truncateToDate(fi.ModTime())
+	if date, ok := parseVaultDate(fmFirst(fm, fmUpdated, "modified", "edited", "updated_at")); ok {
+		idear.Edited = date
+	}
+
+	// the file system holds no reliable creation date (ctime is the time of the
+	// last inode change) so without front matter the edited date is used
+	idear.Created = idear.Edited
+	if date, ok := parseVaultDate(fmFirst(fm, fmCreated, "date", "created_at")); ok {
+		idear.Created = date
+	}
+	if cycle, err := idea.ParseCycleName(fmFirst(fm, fmCycle)); err == nil && cycle != CycleAlive {
+		idear.Cycle = cycle
+		idear.Consumed = idear.Edited
+		if date, ok := parseVaultDate(fmFirst(fm, fmConsumed)); ok {
+			idear.Consumed = date
+		}
+	}
+
+	var tagStrs []string
+	tagStrs = append(tagStrs, fm[fmTags]...)
+	tagStrs = append(tagStrs, fm["tag"]...)
+	for _, match := range rxInlineTag.FindAllStringSubmatch(body, -1) {
+		tagStrs = append(tagStrs, match[1])
+	}
+	idear.Tags = importTags(tagStrs)
+	note.idea = idear
+	return note, nil
+}
+
+// ExportMarkdownVault writes the ideas into the directory as markdown notes
+// with front matter so that they may be imported again with
+// ImportMarkdownVault. Notes are named by their id so [[id]] links resolve
+// within the vault. Images and audio are written into an attachments directory
+// and embedded within a note. Encrypted ideas are only exported (decrypted)
+// when IncludeEncrypted is set.
+func ExportMarkdownVault(idears idea.Ideas, dir string) (exported idea.Ideas, err error) {
+	err = os.MkdirAll(path.Join(dir, vaultAttachmentsDir), os.ModePerm)
+	if err != nil {
+		return exported, err
+	}
+	for _, idear := range idears {
+		var body string
+		switch idear.Kind {
+		case KindText:
+			body = idea.ReplaceLinks(string(idear.GetContent()), func(id uint32, _ string) string {
+				return "[[" + idea.IdStr(id) + "]]"
+			})
+		case KindEnText:
+			if !idea.IncludeEncrypted {
+				continue
+			}
+			bz, err := idear.GetPlainContent()
+			if err != nil {
+				return exported, err
+			}
+			body = string(bz)
+		case KindImage, KindAudio:
+			attachment := path.Join(vaultAttachmentsDir, idea.IdStr(idear.Id)+idear.Ext)
+			err = cmn.Copy(idear.Path(), path.Join(dir, attachment))
+			if err != nil {
+				return exported, err
+			}
+			body = "![[" + attachment + "]]\n"
+		default:
+			continue
+		}
+
+		notePath := path.Join(dir, idea.IdStr(idear.Id)+".md")
+		err = ioutil.WriteFile(notePath, []byte(vaultFrontMatter(idear)+body), os.ModePerm)
+		if err != nil {
+			return exported, err
+		}
+		_ = os.Chtimes(notePath, idear.Edited, idear.Edited)
+		exported = append(exported, idear)
+	}
+	return exported, nil
+}
+
+func vaultFrontMatter(idear idea.Idea) string {
+	quote := func(in string) string {
+		if strings.ContainsAny(in, ":#[]{},&*!|>'\"%@`") {
+			return `"` + strings.Replace(in, `"`, `\"`, -1) + `"`
+		}
+		return in
+	}
+	var tags, consumes []string
+	for _, tag := range idear.Tags {
+		tags = append(tags, quote(tag.String()))
+	}
+	for _, cid := range idear.ConsumesIds {
+		consumes = append(consumes, `"`+idea.IdStr(cid)+`"`)
+	}
+	sort.Strings(consumes)
+
+	fm := "---\n"
+	fm += fmt.Sprintf("%v: \"%v\"\n", fmId, idea.IdStr(idear.Id))
+	fm += fmt.Sprintf("%v: [%v]\n", fmTags, strings.Join(tags, ", "))
+	fm += fmt.Sprintf("%v: %v\n", fmCreated, idear.Created.Format(cmn.LayoutYYYYdMMdDD))
+	fm += fmt.Sprintf("%v: %v\n", fmUpdated, idear.Edited.Format(cmn.LayoutYYYYdMMdDD))
+	if idear.Cycle != CycleAlive {
+		fm += fmt.Sprintf("%v: %v\n", fmCycle, idea.CycleName(idear.Cycle))
+		fm += fmt.Sprintf("%v: %v\n", fmConsumed, idear.Consumed.Format(cmn.LayoutYYYYdMMdDD))
+	}
+	if len(consumes) > 0 {
+		fm += fmt.Sprintf("%v: [%v]\n", fmConsumes, strings.Join(consumes, ", "))
+	}
+	return fm + "---\n"
+}
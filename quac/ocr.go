@@ -0,0 +1,163 @@
+package quac
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// OCRProvider produces draft transcriptions of images
+type OCRProvider interface {
+	Draft(imagePath string) (string, error)
+}
+
+// ocr= within the thranch config, either tesseract (the default when found),
+// none, or any other command which is run with the image path appended and
+// prints the draft. ocr-lang= sets the tesseract language (such as eng).
+var OCRCommand, OCRLang string
+
+// GetOCRProvider returns the configured OCR provider, nil if there is none
+func GetOCRProvider() OCRProvider {
+	fields := strings.Fields(OCRCommand)
+	switch {
+	case len(fields) == 0:
+		if bin, err := exec.LookPath("tesseract"); err == nil {
+			return TesseractOCR{bin, OCRLang}
+		}
+		return nil
+	case fields[0] == "none":
+		return nil
+	case path.Base(fields[0]) == "tesseract" && len(fields) == 1:
+		return TesseractOCR{fields[0], OCRLang}
+	}
+	return CommandOCR{fields[0], fields[1:]}
+}
+
+// ------------------------------------------
+// TesseractOCR runs the local tesseract cli
+type TesseractOCR struct {
+	Bin  string
+	Lang string
+}
+
+var _ OCRProvider = TesseractOCR{}
+
+func (t TesseractOCR) Draft(imagePath string) (string, error) {
+	args := []string{imagePath, "stdout"}
+	if t.Lang != "" {
+		args = append(args, "-l", t.Lang)
+	}
+	return runOCRCommand(t.Bin, args...)
+}
+
+// ------------------------------------------
+// CommandOCR runs a command with the image path appended
+type CommandOCR struct {
+	Bin  string
+	Args []string
+}
+
+var _ OCRProvider = CommandOCR{}
+
+func (c CommandOCR) Draft(imagePath string) (string, error) {
+	return runOCRCommand(c.Bin, append(append([]string{}, c.Args...), imagePath)...)
+}
+
+func runOCRCommand(bin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", bin, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// ------------------------------------------
+// FakeOCR returns the drafts by the filename of the image, for tests
+type FakeOCR struct {
+	Drafts map[string]string
+	Calls  int
+}
+
+var _ OCRProvider = &FakeOCR{}
+
+func (f *FakeOCR) Draft(imagePath string) (string, error) {
+	f.Calls++
+	draft, found := f.Drafts[path.Base(imagePath)]
+	if !found {
+		return "", fmt.Errorf("no draft for %v", path.Base(imagePath))
+	}
+	return draft, nil
+}
+
+// ------------------------------------------
+
+// WriteOCRDraft generates and stores the OCR draft of the image idea
+func WriteOCRDraft(provider OCRProvider, idear idea.Idea) (draft string, err error) {
+	if !idear.IsImage() {
+		return "", fmt.Errorf("not an image: %v", idear.Filename)
+	}
+	draft, err = provider.Draft(idear.Path())
+	if err != nil {
+		return "", err
+	}
+
+	// tesseract ends the page with a form feed
+	draft = strings.TrimSpace(strings.Replace(draft, "\f", "", -1))
+	if draft == "" {
+		return "", fmt.Errorf("no text recognized in %v", idear.Filename)
+	}
+	draft += "\n"
+	draftPath, err := idear.OCRDraftPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(idea.OCRDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	return draft, ioutil.WriteFile(draftPath, []byte(draft), os.ModePerm)
+}
+
+// GenerateOCRDrafts stores the OCR drafts of the image ideas which do not yet
+// have one (or all of them if overwrite), returning the number generated
+func GenerateOCRDrafts(provider OCRProvider, idears idea.Ideas, overwrite bool,
+	progress func(idea.Idea, error)) (generated int) {
+
+	for _, idear := range idears.WithImage() {
+		if _, found := idear.GetOCRDraft(); found && !overwrite {
+			continue
+		}
+		_, err := WriteOCRDraft(provider, idear)
+		if err == nil {
+			generated++
+		}
+		if progress != nil {
+			progress(idear, err)
+		}
+	}
+	return generated
+}
+
+// GetTranscriptionDraft returns the OCR draft of the image idea, generating
+// it if there is none and an OCR provider is configured (otherwise blank)
+func GetTranscriptionDraft(idear idea.Idea) (draft string, err error) {
+	if !idear.IsImage() {
+		return "", nil
+	}
+	if draft, found := idear.GetOCRDraft(); found {
+		return draft, nil
+	}
+	provider := GetOCRProvider()
+	if provider == nil {
+		return "", nil
+	}
+	return WriteOCRDraft(provider, idear)
+}
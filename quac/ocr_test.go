@@ -0,0 +1,70 @@
+package quac
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+func TestGenerateOCRDrafts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ocr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	idea.IdeasDir, idea.OCRDir = dir, dir
+
+	receipt := idea.Idea{Id: 1, Filename: "a,000001,2020-01-01,e2020-01-01,receipt.png",
+		Ext: ".png", Kind: idea.KindImage}
+	note := idea.Idea{Id: 2, Filename: "a,000002,2020-01-01,e2020-01-01,note", Kind: idea.KindText}
+	if err := ioutil.WriteFile(receipt.Path(), []byte("\x89PNG"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider := &FakeOCR{Drafts: map[string]string{receipt.Filename: "coffee 4.50\n\f"}}
+
+	if n := GenerateOCRDrafts(provider, idea.Ideas{receipt, note}, false, nil); n != 1 {
+		t.Fatalf("expected 1 draft generated, got %v", n)
+	}
+	if draft, found := receipt.GetOCRDraft(); !found || draft != "coffee 4.50\n" {
+		t.Errorf("unexpected draft %q", draft)
+	}
+	if n := GenerateOCRDrafts(provider, idea.Ideas{receipt}, false, nil); n != 0 || provider.Calls != 1 {
+		t.Errorf("expected the existing draft to be kept, generated %v", n)
+	}
+
+	// the draft follows the image when it is renumbered
+	renumbered := idea.Idea{Id: 7, Filename: "a,000007,2020-01-01,e2020-01-01,receipt.png",
+		Ext: ".png", Kind: idea.KindImage}
+	if err := os.Rename(receipt.Path(), renumbered.Path()); err != nil {
+		t.Fatal(err)
+	}
+	if draft, found := renumbered.GetOCRDraft(); !found || draft != "coffee 4.50\n" {
+		t.Errorf("draft lost on renumbering, got %q", draft)
+	}
+	if err := os.Rename(renumbered.Path(), receipt.Path()); err != nil {
+		t.Fatal(err)
+	}
+
+	contains, err := idea.NewTagContains(idea.ContainsKeyword, "coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idea.SearchOCRDrafts = false
+	if receipt.HasTags(contains) {
+		t.Error("draft searched without ocr-search")
+	}
+	idea.SearchOCRDrafts = true
+	defer func() { idea.SearchOCRDrafts = false }()
+	if !receipt.HasTags(contains) {
+		t.Error("draft not searched with ocr-search")
+	}
+
+	if err := receipt.RemoveOCRDraft(); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := receipt.GetOCRDraft(); found {
+		t.Error("draft not removed")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rigelrozanski/thranch/quac"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// backup actions
+const (
+	backupList    = "list"
+	backupRestore = "restore"
+)
+
+func Backup(args []string) {
+	if len(args) == 0 {
+		args = []string{backupDir()}
+	}
+	switch args[0] {
+	case backupList:
+		snapshots, err := quac.ListBackups(backupDir())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("no snapshots found")
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%v\t%v files\n", s.Name, s.Files)
+		}
+	case backupRestore:
+		EnsureLenAtLeast(args, 2)
+		snapshot, err := quac.GetBackupSnapshot(backupDir(), args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(args) > 2 {
+			restoreBackupIdeas(snapshot, args[2])
+			return
+		}
+
+		// the current ranch is first backed up so the restore may be undone
+		current, _, err := quac.Backup(backupDir())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("current ranch backed up to snapshot: %v\n", current.Name)
+		restored, err := quac.RestoreBackup(snapshot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("restored %v files from snapshot: %v\n", restored, snapshot.Name)
+	default:
+		destDir := args[0]
+		snapshot, linked, err := quac.Backup(destDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := quac.SetBackupDir(destDir); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("snapshot %v: %v files (%v unchanged and linked)\n",
+			snapshot.Dir, snapshot.Files, linked)
+	}
+}
+
+func backupDir() string {
+	dir := quac.GetBackupDir()
+	if dir == "" {
+		log.Fatal("no backup destination, provide one or set backup-dir in the thranch config")
+	}
+	return dir
+}
+
+// restore only the ideas of the snapshot which match the query
+func restoreBackupIdeas(snapshot quac.BackupSnapshot, query string) {
+	all, err := snapshot.Ideas()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ideas idea.Ideas
+	idStart, idEnd, isRange := IsIDorIDRange(query)
+	if isRange {
+		ideas = all.InRange(idStart, idEnd)
+	} else {
+		ideas = all.WithTags(idea.ParseClumpedTags(query))
+	}
+	if len(ideas) == 0 {
+		fmt.Printf("nothing found within snapshot %v for the query %v\n", snapshot.Name, query)
+		return
+	}
+	if err := quac.RestoreBackupIdeas(snapshot, ideas); err != nil {
+		log.Fatal(err)
+	}
+	for _, idear := range ideas {
+		fmt.Printf("restored: %v\n", idear.Filename)
+	}
+}
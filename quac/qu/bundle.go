@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rigelrozanski/thranch/quac"
+)
+
+// bundle actions
+const (
+	bundleExport = "export"
+	bundleImport = "import"
+)
+
+func Bundle(action string, args []string) {
+	switch action {
+	case bundleExport:
+		EnsureLenAtLeast(args, 2)
+		ideas := QueryIdeas(args[0], true)
+		f, err := os.Create(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		bundled, err := quac.ExportBundle(ideas, f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%v ideas (%v from lineage) bundled to: %v\n",
+			len(bundled), len(bundled)-len(ideas), args[1])
+	case bundleImport:
+		EnsureLenAtLeast(args, 1)
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		imported, err := quac.ImportBundle(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printImported(imported)
+	default:
+		fmt.Printf("unknown bundle action %v\n", action)
+	}
+}
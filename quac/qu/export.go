@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/rigelrozanski/thranch/quac"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// export kinds
+const (
+	exportRelations = "relations"
+	exportSite      = "site"
+	exportJSON      = "json"
+	exportVault     = "vault"
+)
+
+func Export(kind string, args []string) {
+	switch kind {
+	case exportRelations:
+		ideas := quac.GetAllIdeas()
+		if len(args) > 0 {
+			ideas = QueryIdeas(args[0], true)
+		}
+		err := quac.ExportRelationsCSV(ideas, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case exportSite:
+		args, includeConsumed := PopFlag(args, "--consumed")
+		args, includeEncrypted := PopFlag(args, "--encrypted")
+		EnsureLenAtLeast(args, 1)
+		ideas := quac.GetAllIdeas()
+		outDir := args[0]
+		if len(args) > 1 {
+			ideas = QueryIdeas(args[0], true)
+			outDir = args[1]
+		}
+		opts := quac.SiteOptions{
+			IncludeConsumed:  includeConsumed,
+			IncludeEncrypted: includeEncrypted,
+		}
+		err := quac.ExportSite(ideas, outDir, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("site exported to: %v\n", outDir)
+	case exportJSON:
+		args, ndjson := PopFlag(args, "--ndjson")
+		args, idea.IncludeEncrypted = popIncludeEncrypted(args)
+		ideas := quac.GetAllIdeas()
+		if len(args) > 0 {
+			ideas = QueryIdeas(args[0], true)
+		}
+		err := quac.ExportJSON(ideas, os.Stdout, ndjson)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case exportVault:
+		args, idea.IncludeEncrypted = popIncludeEncrypted(args)
+		EnsureLenAtLeast(args, 1)
+		ideas := quac.GetAllIdeas()
+		outDir := args[0]
+		if len(args) > 1 {
+			ideas = QueryIdeas(args[0], true)
+			outDir = args[1]
+		}
+		exported, err := quac.ExportMarkdownVault(ideas, outDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%v ideas exported to: %v\n", len(exported), outDir)
+	default:
+		fmt.Printf("unknown export kind %v\n", kind)
+	}
+}
+
+// the --encrypted flag includes the decrypted content of encrypted ideas, as
+// does include-encrypted within the thranch config
+func popIncludeEncrypted(args []string) ([]string, bool) {
+	args, found := PopFlag(args, "--encrypted")
+	return args, found || idea.IncludeEncrypted
+}
+
+func Graph(args []string) {
+	args, format, found := PopFlagValue(args, "--format")
+	if !found {
+		format = quac.GraphFormatDOT
+	}
+	args, includeTags := PopFlag(args, "--tags")
+
+	ideas := quac.GetAllIdeas()
+	if len(args) > 0 {
+		ideas = QueryIdeas(args[0], true)
+	}
+	err := quac.NewGraph(ideas, includeTags).Write(os.Stdout, format)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+
+	"github.com/rigelrozanski/thranch/quac"
+)
+
+// import kinds
+const (
+	importJSON      = "json"
+	importVault     = "vault"
+	importENEX      = "enex"
+	importClippings = "clippings"
+	importMaildir   = "maildir"
+)
+
+func Import(kind string, args []string) {
+	switch kind {
+	case importJSON:
+		args, preserveIds := PopFlag(args, "--preserve-ids")
+		EnsureLenAtLeast(args, 1)
+		in, baseDir := os.Stdin, "."
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			in, baseDir = f, path.Dir(args[0])
+		}
+		recs, err := quac.ReadIdeaRecords(in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		imported, err := quac.ImportJSON(recs, baseDir, preserveIds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printImported(imported)
+	case importVault:
+		args, preserveIds := PopFlag(args, "--preserve-ids")
+		EnsureLenAtLeast(args, 1)
+		imported, warnings, err := quac.ImportMarkdownVault(args[0], preserveIds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printImported(imported)
+		for _, warning := range warnings {
+			fmt.Printf("warning: %v\n", warning)
+		}
+	case importENEX:
+		EnsureLenAtLeast(args, 1)
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		imported, err := quac.ImportENEX(f)
+		printImported(imported)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case importClippings:
+		EnsureLenAtLeast(args, 1)
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		clips, err := quac.ParseClippings(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		imported, skipped, err := quac.ImportClippings(clips)
+		printImported(imported)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%v clippings skipped (already imported or bookmarks)\n", skipped)
+	case importMaildir:
+		args, extraTags, _ := PopFlagValue(args, "--tags")
+		EnsureLenAtLeast(args, 1)
+		imported, err := quac.ImportMaildir(args[0], extraTags)
+		printImported(imported)
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		fmt.Printf("unknown import kind %v\n", kind)
+	}
+}
+
+func printImported(imported quac.Ideas) {
+	for _, idea := range imported {
+		fmt.Printf("imported: %v\n", idea.Filename)
+	}
+	fmt.Printf("%v ideas imported\n", len(imported))
+}
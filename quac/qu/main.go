@@ -35,9 +35,16 @@ const (
 	keyTagUntagged     = "tag-untagged"
 	keyConsume         = "consume"
 	keyConsumes        = "consumes"
+	keyMerge           = "merge"
+	keySplit           = "split"
 	keyZombie          = "zombie"
+	keyRelate          = "relate"
+	keyUnrelate        = "unrelate"
+	keyRelations       = "relations"
 	keyLineage         = "lineage"
 	keyNew             = "new"
+	keyRecord          = "record"
+	keyOCR             = "ocr"
 	keyManualEntry     = "manual-entry"
 	keySetEncryption   = "set-encryption"
 	keyRm              = "rm"
@@ -54,17 +61,28 @@ const (
 	keyLSFile          = "lsfl"
 	keySelectFiles     = "sel"
 	keyPDFBackup       = "pdf-backup"
+	keyBackup          = "backup"
+	keyExport          = "export"
+	keyImport          = "import"
+	keyGraph           = "graph"
+	keyBundle          = "bundle"
+	keySync            = "sync"
+	keyIdBlocks        = "id-blocks"
+	keyUnlock          = "unlock"
+	keyLock            = "lock"
 	keyForceSplit      = "force-split"
 	keyOpenWorking     = "open-working"
 	keySaveWorking     = "save-working"
+	keyVaultWatch      = "vault-watch" // internal, started by unlock
 
 	help = `
 /|||||\ |-o-o-~|
 🦆 🦆 🦆 ✍️  🏁
 
-qu ---------------------------------------> edit the tagless master idea in vim
-qu [force-split] <query> -----------------> open a vim tab with the contents of the query 
+qu ---------------------------------------> edit the tagless master idea in your editor
+qu [force-split] <query> -----------------> open the editor with the contents of the query
 qu new <tags> ----------------------------> create a new idea with the provided tags
+qu record <tags> -------------------------> record a new audio idea (.wav) with the provided tags
 qu cat <query> ---------------------------> print idea(s) contents' to console
 qu ls [query] ----------------------------> list ideas which match the [query], if no query is
                                               provided list recently opened ideas
@@ -75,13 +93,27 @@ qu empty-trash ---------------------------> empty the trash can optionally appen
 -- ENTRY --
 qu scan <dir/file> [tags] ----------------> add provided image(s) to untranscribed ideas, 
 qu tag-untagged --------------------------> iterate and add tags to ideas with the tag "UNTAGGED"
-qu transcribe [query] --------------------> transcribe either a random untranscribed image 
+qu transcribe [query] --------------------> transcribe either a random untranscribed image or audio
+qu ocr [query] [--force] -----------------> generate OCR drafts of image ideas without one (all
+                                              with --force), used to pre-fill transcriptions,
+                                              by default of images which may be transcribed
 qu wc ------------------------------------> (water closet) tag-untagged all then transcribe
 qu manual-entry [tags] -------------------> interactive manual entry common tags may be entered 
 qu consume <id> [entry] ------------------> quick consumes the given id into a new entry
 qu consumes <consumed-id> <consumer-id> --> set the consumption of existing ideas
+qu merge <query|ids> ---------------------> merge the text of many ideas into a new idea which
+                                              consumes them all (ids seperated by commas)
+qu split <id> ----------------------------> split an idea in the editor into new ideas at each
+                                              "--- tags:foo,bar" line (tags optional)
 qu zombie <id> ---------------------------> "unconsume" an idea based on id
-qu lineage <id> --------------------------> show the consumtion lineage  
+qu lineage <id> --------------------------> show the full consumption lineage as ancestor
+                                              and descendant trees
+
+-- RELATIONS --
+qu relate <id1> <rel> <id2> --------------> relate two ideas where <rel> is one of: supports,
+                                              contradicts, extends, prerequisite
+qu unrelate <id1> [rel] <id2> ------------> remove the relation(s) from <id1> to <id2>
+qu relations <id> ------------------------> list all relations to and from an idea
 
 -- TAGS MANAGEMENT --
 qu common-tags [tags] --------------------> list all tags which share a set of common [tags]
@@ -93,10 +125,57 @@ qu destroy-tag <tag> ---------------------> remove all instances of a tag for al
 
 -- OTHER --
 qu qe <tags...> <entry> ------------------> quick entry to a new idea
-qu set-encryption <id> -------------------> set encryption of existing idea
-qu open-working --------------------------> open the working split files to manually correct mistakes
-qu save-working --------------------------> save the working split files to manually correct mistakes
+qu set-encryption <id> -------------------> encrypt an existing text idea with a passphrase
+                                              (aes-gcm), prompted for once per command
+qu open-working --------------------------> open the working split files (or the single file
+                                              working view) to manually correct mistakes
+qu save-working --------------------------> save the working split files (or the single file
+                                              working view) to manually correct mistakes
 qu pdf-backup ----------------------------> backup active ideas to a printable pdf
+qu backup [dest-dir] ---------------------> snapshot the ranch into dest-dir (default the last
+                                              used or backup-dir of the config), unchanged
+                                              files are hard-linked to the previous snapshot
+qu backup list ---------------------------> list the snapshots within the backup destination
+qu backup restore <snapshot> [query] -----> restore the whole ranch (after snapshotting it) or
+                                              only the ideas of the snapshot matching the query
+qu export relations [query] --------------> export relations as csv for a map of understanding
+qu export site [query] <outdir> ----------> export a browsable static html site of alive ideas
+                                              flags: --consumed to include consumed ideas,
+                                              --encrypted to include encrypted ideas
+qu export json [query] [--ndjson] --------> dump ideas as json records (one per line w/ --ndjson)
+                                              --encrypted to include decrypted encrypted ideas
+qu import json <file> [--preserve-ids] ---> recreate ideas from a json dump ("-" for stdin), ids
+                                              are remapped unless preserved and not colliding
+qu export vault [query] <dir> ------------> export ideas as a markdown vault with front matter
+                                              --encrypted to include decrypted encrypted ideas
+qu import vault <dir> [--preserve-ids] ---> import the markdown notes of a vault (e.g. obsidian)
+                                              converting front matter, #tags and [[wikilinks]]
+qu import enex <file.enex> ---------------> import the notes of an evernote export, images and
+                                              audio are imported as ideas consumed by the note
+qu import clippings <My Clippings.txt> ---> import kindle highlights and notes tagged by book,
+                                              author and location, skipping those already imported
+qu import maildir <dir> [--tags tags] ----> import unseen messages of a maildir, the subject is
+                                              parsed as tags, attachments become image or audio
+                                              ideas and messages are then marked as seen
+qu bundle export <query> <out.tar.gz> ----> bundle the ideas, all they have consumed and a
+                                              manifest for sharing with another ranch
+qu bundle import <bundle.tar.gz> ---------> import a bundle with fresh ids, rewriting consumes
+                                              ids, [[id]] links and relations to match
+qu sync <other-qu-dir> -------------------> three-way merge with another ranch since the last
+                                              sync, flags: --dry-run to only report, --prefer
+                                              local|remote to resolve content conflicts
+qu id-blocks -----------------------------> list the blocks of ids reserved by each device
+qu id-blocks reserve ---------------------> reserve a block of ids for this device, only while
+                                              the id_blocks file is up to date with every device
+qu unlock --------------------------------> unlock the vault (vault=true within the config)
+                                              for vault-timeout (default 15m) after the last
+                                              command, the first unlock creates the vault
+qu lock ----------------------------------> encrypt changes into the vault and wipe the
+                                              unlocked ideas
+qu graph [query] [--format f] [--tags] ---> output the graph of consumption, links and relations
+                                              between ideas where f is dot (default), graphml
+                                              or json. --tags includes tags and their
+                                              co-occurrence
 qu stats ---------------------------------> statistics on your ideas
 qu sel [tags]-----------------------------> select the idea from the tags (in cui)
 qu lsfl [query] --------------------------> list all files by file location
@@ -119,6 +198,13 @@ tags ----------- a list of tags seperated by commas (such as "tag1,tag2,tag3")
 				   CONTAINS-CI=foo    <- same as CONTAINS but case-insensitive
 				   NO-CONTAINS=foo    <- excludes ideas which contain the text 'foo' 
 				   NO-CONTAINS-CI=foo <- same as NO-CONTAINS but case-insensitive
+				                         (encrypted ideas are only searched with
+				                         include-encrypted=true in the config, and the
+				                         OCR drafts of images with ocr-search=true)
+				   DESCENDS-FROM=id   <- include ideas which (eventually) consume the id
+				   ANCESTOR-OF=id     <- include ideas (eventually) consumed by the id
+				   REL=supports:id    <- include ideas which have the relation to the id
+				                         (the id may be omitted)
 				   *NOTE: Within these examples 'foo' may also be an array 
 				          in the format of ['foo','bar']
 entry ---------- either raw input text or source input as a file or directory
@@ -128,8 +214,24 @@ force-split ---- if the text "force-split" is included, split view will be used
 )
 
 func main() {
-	quac.Initialize(os.ExpandEnv("$HOME/.thranch_config"))
 	args := os.Args[1:]
+	if len(args) > 0 && args[0] == keyVaultWatch {
+		quac.ExtendVaultSession = false
+	}
+	quac.Initialize(os.ExpandEnv("$HOME/.thranch_config"))
+
+	// within vault mode only a few commands may be used while locked
+	if quac.VaultLocked && (len(args) == 0 || !lockedVaultCommands[args[0]]) {
+		log.Fatal("the vault is locked, run: qu unlock")
+	}
+
+	// store the changes of an unlocked vault once the command is done, not
+	// run when the command exits on an error
+	defer func() {
+		if err := quac.StoreVaultSession(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
 	// for the master qu file for quick entry
 	if len(args) == 0 {
@@ -182,15 +284,39 @@ func main() {
 	case keyConsumes:
 		EnsureLenAtLeast(args, 3)
 		Consumes(args[1], args[2])
+	case keyMerge:
+		EnsureLenAtLeast(args, 2)
+		Merge(args[1])
+	case keySplit:
+		EnsureLenAtLeast(args, 2)
+		Split(args[1])
 	case keyZombie:
 		EnsureLenAtLeast(args, 2)
 		Zombie(args[1])
+	case keyRelate:
+		EnsureLenAtLeast(args, 4)
+		Relate(args[1], args[2], args[3])
+	case keyUnrelate:
+		switch len(args) {
+		case 3:
+			Unrelate(args[1], "", args[2])
+		case 4:
+			Unrelate(args[1], args[2], args[3])
+		default:
+			EnsureLenAtLeast(args, 3)
+		}
+	case keyRelations:
+		EnsureLenAtLeast(args, 2)
+		Relations(args[1])
 	case keyLineage:
 		EnsureLenAtLeast(args, 2)
 		Lineage(args[1])
 	case keyNew:
 		EnsureLenAtLeast(args, 2)
 		NewEmptyEntry(strings.Join(args[1:], " "))
+	case keyRecord:
+		EnsureLenAtLeast(args, 2)
+		Record(strings.Join(args[1:], " "))
 	case keyManualEntry:
 		if len(args) == 1 {
 			ManualEntry("")
@@ -252,6 +378,31 @@ func main() {
 		}
 	case keyPDFBackup:
 		quac.ExportToPDF()
+	case keyOCR:
+		OCR(args[1:])
+	case keyBackup:
+		Backup(args[1:])
+	case keyExport:
+		EnsureLenAtLeast(args, 2)
+		Export(args[1], args[2:])
+	case keyImport:
+		EnsureLenAtLeast(args, 2)
+		Import(args[1], args[2:])
+	case keyGraph:
+		Graph(args[1:])
+	case keyBundle:
+		EnsureLenAtLeast(args, 2)
+		Bundle(args[1], args[2:])
+	case keySync:
+		Sync(args[1:])
+	case keyIdBlocks:
+		IdBlocks(args[1:])
+	case keyUnlock:
+		Unlock()
+	case keyVaultWatch:
+		VaultWatch()
+	case keyLock:
+		Lock()
 	case keyStats:
 		quac.GetStats()
 	case keyForceSplit:
@@ -270,6 +421,34 @@ func main() {
 	}
 }
 
+// remove the flag from the args, returning whether it was found
+func PopFlag(args []string, flag string) (remaining []string, found bool) {
+	for _, arg := range args {
+		if arg == flag {
+			found = true
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	return remaining, found
+}
+
+// remove the flag and the value which follows it from the args
+func PopFlagValue(args []string, flag string) (remaining []string, value string, found bool) {
+	for i := 0; i < len(args); i++ {
+		if args[i] == flag {
+			if i+1 == len(args) {
+				log.Fatalf("%v requires a value", flag)
+			}
+			value, found = args[i+1], true
+			i++
+			continue
+		}
+		remaining = append(remaining, args[i])
+	}
+	return remaining, value, found
+}
+
 func EnsureLenAtLeast(args []string, enLen int) {
 	if len(args) < enLen {
 		log.Fatalf("expected at least %v args", enLen)
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/rigelrozanski/thranch/quac"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// OCR generates the drafts of image ideas (of the query) without one, by
+// default the images which would be offered for transcription
+func OCR(args []string) {
+	args, overwrite := PopFlag(args, "--force")
+	provider := quac.GetOCRProvider()
+	if provider == nil {
+		log.Fatal(errors.New("no OCR provider, install tesseract or set ocr= within the thranch config"))
+	}
+	wot, _ := idea.NewTagWithout(idea.WithoutKeyword, "DNT")
+	ideas := quac.GetAllIdeasNonConsuming().WithImage().WithTags(wot)
+	if len(args) > 0 {
+		ideas = QueryIdeas(args[0], true)
+	}
+	generated := quac.GenerateOCRDrafts(provider, ideas, overwrite,
+		func(idear idea.Idea, err error) {
+			if err != nil {
+				fmt.Printf("failed: %v: %v\n", idear.Filename, err)
+				return
+			}
+			fmt.Printf("drafted: %v\n", idear.Filename)
+		})
+	fmt.Printf("%v OCR drafts generated\n", generated)
+}
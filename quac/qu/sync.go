@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rigelrozanski/thranch/quac"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+func Sync(args []string) {
+	args, dryRun := PopFlag(args, "--dry-run")
+	args, prefer, _ := PopFlagValue(args, "--prefer")
+	EnsureLenAtLeast(args, 1)
+	report, conflicts, err := quac.Sync(args[0], quac.SyncOptions{DryRun: dryRun, Prefer: prefer})
+	for _, line := range report {
+		fmt.Println(line)
+	}
+	for _, conflict := range conflicts {
+		fmt.Printf("CONFLICT %v\n", conflict)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(report) == 0 && len(conflicts) == 0 {
+		fmt.Println("already in sync")
+	}
+	if len(conflicts) > 0 {
+		fmt.Printf("%v conflicts, the other side of each is saved within the sync/conflicts "+
+			"directory, resolve with --prefer or by making both sides the same\n", len(conflicts))
+	}
+}
+
+func IdBlocks(args []string) {
+	if len(args) > 0 && args[0] == "reserve" {
+		if idea.DeviceName == "" {
+			log.Fatal("no device set within the thranch config")
+		}
+		b := idea.ReserveIdBlock()
+		fmt.Printf("reserved %v-%v for device %v\n", idea.IdStr(b.Start), idea.IdStr(b.End), b.Device)
+		return
+	}
+	if idea.DeviceName == "" {
+		fmt.Println("no device set within the thranch config, ids are allocated from the single counter")
+	}
+	for _, b := range idea.GetIdBlocks() {
+		fmt.Printf("%v\t%v-%v\tlast: %v\n", b.Device, idea.IdStr(b.Start), idea.IdStr(b.End), idea.IdStr(b.Last))
+	}
+}
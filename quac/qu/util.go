@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -42,6 +43,35 @@ func Consumes(consumedID, consumesID string) {
 	quac.SetConsumes(uint32(consumed), uint32(consumes))
 }
 
+func Merge(query string) {
+	ideas := QueryIdeas(query, false)
+	if len(ideas) == 0 {
+		fmt.Printf("nothing found for the query %v\n", query)
+		os.Exit(1)
+	}
+	consumerFilepath, err := quac.SetMerge(ideas)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("merged into: %v\n", consumerFilepath)
+}
+
+func Split(idStr string) {
+	id, err := quac.ParseID(idStr)
+	if err != nil {
+		log.Fatalf("bad id %v", idStr)
+	}
+	fmt.Printf("split the idea with lines beginning with \"%v\" followed by"+
+		" any additional tags\n", quac.SplitMarker)
+	newFilepaths, err := quac.SplitByID(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, fp := range newFilepaths {
+		fmt.Printf("split out: %v\n", fp)
+	}
+}
+
 func Zombie(zombieID string) {
 	zombie, err := quac.ParseID(zombieID)
 	if err != nil {
@@ -58,6 +88,55 @@ func Lineage(idStr string) {
 	fmt.Print(quac.GetLineage(uint32(id)))
 }
 
+func Relate(fromIDStr, relType, toIDStr string) {
+	from, err := quac.ParseID(fromIDStr)
+	if err != nil {
+		log.Fatalf("bad id %v", fromIDStr)
+	}
+	to, err := quac.ParseID(toIDStr)
+	if err != nil {
+		log.Fatalf("bad id %v", toIDStr)
+	}
+	err = quac.Relate(from, relType, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Unrelate(fromIDStr, relType, toIDStr string) {
+	from, err := quac.ParseID(fromIDStr)
+	if err != nil {
+		log.Fatalf("bad id %v", fromIDStr)
+	}
+	to, err := quac.ParseID(toIDStr)
+	if err != nil {
+		log.Fatalf("bad id %v", toIDStr)
+	}
+	err = quac.Unrelate(from, relType, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Relations(idStr string) {
+	id, err := quac.ParseID(idStr)
+	if err != nil {
+		log.Fatalf("bad id %v", idStr)
+	}
+	fmt.Print(quac.GetRelations(id))
+}
+
+// audio ideas to transcribe, recordings made as transcriptions (which
+// consume what they transcribe) are not transcribed again
+func untranscribedAudio(ideas quac.Ideas) (audio quac.Ideas) {
+	for _, idear := range ideas.WithAudio() {
+		if len(idear.ConsumesIds) == 0 {
+			audio = append(audio, idear)
+		}
+	}
+	return audio
+}
+
 func Transcribe(optionalQuery string) {
 
 	consumed, err := quac.ParseID(optionalQuery)
@@ -70,13 +149,13 @@ func Transcribe(optionalQuery string) {
 		}
 		ideaImages = []quac.Idea{idear}
 	} else { // not an id, get by tags
-		wot, _ := idea.NewTagWithout("DNT", "")
-		ideaImages = quac.GetAllIdeasNonConsuming().
-			WithImage().WithTags(wot)
+		wot, _ := idea.NewTagWithout(idea.WithoutKeyword, "DNT")
+		ideas := quac.GetAllIdeasNonConsuming()
+		ideaImages = append(ideas.WithImage(), untranscribedAudio(ideas)...).WithTags(wot)
 		if optionalQuery != "" {
 			ideaImages = ideaImages.WithTags(idea.ParseClumpedTags(optionalQuery))
 			if len(ideaImages) == 0 {
-				fmt.Println("no active images to transcribe with those tags")
+				fmt.Println("no active images or audio to transcribe with those tags")
 				os.Exit(1)
 			}
 		}
@@ -90,8 +169,11 @@ func Transcribe(optionalQuery string) {
 	fmt.Println("                     ~ Instructions ~")
 	fmt.Println("       After each transcription item enter either:")
 	fmt.Println("         - nothing to open up your editor where you")
-	fmt.Println("             may enter the transcription text")
+	fmt.Println("             may enter the transcription text (pre-filled")
+	fmt.Println("             with an OCR draft of images)")
 	fmt.Println("         - transcribed entry text")
+	fmt.Println("         - RECORD to record the transcription as audio")
+	fmt.Println("         - PLAY to play (or view) the entry again")
 	fmt.Println("         - DNT for do not transcribe (DNT is added as")
 	fmt.Println("             a tag and never asked to transcribe again)")
 	fmt.Println("         - ADDTAG <newtag> to add the newtag to the entry")
@@ -123,6 +205,18 @@ IdeaLoop:
 		case optionalEntry == "SKIP":
 			fmt.Println("skipp'd")
 			continue
+		case optionalEntry == "PLAY":
+			quac.Open(idea.Path())
+			goto GETINPUT
+		case optionalEntry == "RECORD":
+			consumerFilepath, err := quac.SetConsumeRecording(idea.Id)
+			if err != nil {
+				fmt.Println(err)
+				fmt.Println("continue transcription:")
+				goto GETINPUT
+			}
+			fmt.Printf("created: %v\n", consumerFilepath)
+			continue
 		case optionalEntry == "KILL":
 			quac.RemoveByID(idea.Id)
 			fmt.Println("killed it")
@@ -145,7 +239,16 @@ IdeaLoop:
 			panic("unimplemented")
 		}
 
-		consumerFilepath := quac.SetConsume(idea.Id, optionalEntry)
+		// pre-fill the editor with any OCR draft
+		entry := optionalEntry
+		if entry == "" {
+			draft, err := quac.GetTranscriptionDraft(idea)
+			if err != nil {
+				fmt.Printf("no OCR draft: %v\n", err)
+			}
+			entry = draft
+		}
+		consumerFilepath := quac.SetConsume(idea.Id, entry)
 		if optionalEntry == "" {
 			quac.OpenText(consumerFilepath)
 		}
@@ -233,6 +336,14 @@ func NewEmptyEntry(clumpedTags string) {
 	quac.OpenText(writePath)
 }
 
+func Record(clumpedTags string) {
+	writePath, err := quac.RecordAudioEntry(clumpedTags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("created: %v\n", writePath)
+}
+
 func ManualEntry(commonTagsClumped string) {
 
 	fmt.Println("_________________________________________")
@@ -295,9 +406,7 @@ func MultiOpen(unsplitTagsOrID string, forceSplitView bool) {
 		}
 		if forceSplitView {
 			maxFNLen := quac.WriteWorkingContentAndFilenamesFromFilePath(filePath)
-			origBzFN, origBzContent := quac.GetOrigWorkingFileBytes()
-			quac.OpenTextSplit(quac.WorkingFnsFile, quac.WorkingContentFile, maxFNLen)
-			quac.SaveFromWorkingFiles(origBzFN, origBzContent)
+			quac.EditWorkingFiles(maxFNLen)
 			return
 		}
 		quac.Open(filePath)
@@ -308,10 +417,23 @@ func MultiOpen(unsplitTagsOrID string, forceSplitView bool) {
 }
 
 func OpenWorking() {
+	if quac.UseWorkingView() {
+		quac.EditText(quac.WorkingViewFile)
+		return
+	}
 	quac.OpenTextSplit(quac.WorkingFnsFile, quac.WorkingContentFile, 50)
 }
 
 func SaveWorking() {
+	if quac.UseWorkingView() {
+		if errs := quac.SaveWorkingView(); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
 	quac.SaveFromWorkingFiles([]byte{}, []byte{})
 }
 
@@ -522,6 +644,47 @@ func IsIDorIDRange(query string) (idStart, idEnd uint32, isIDorIDRange bool) {
 	return idStart, idEnd, true
 }
 
+// get the ideas for a query which may be an id, id range, list of ids
+// seperated by commas (in the order provided), or tags. Ideas found by id
+// range or tags are ordered by id.
+func QueryIdeas(query string, includeConsumed bool) (ideas idea.Ideas) {
+	all := quac.GetAllIdeas()
+	if !includeConsumed {
+		all = quac.GetAllIdeasNonConsuming()
+	}
+
+	idStart, idEnd, isRange := IsIDorIDRange(query)
+	if isRange {
+		ideas = all.InRange(idStart, idEnd)
+		sort.Slice(ideas, func(i, j int) bool { return ideas[i].Id < ideas[j].Id })
+		return ideas
+	}
+
+	idStrs := strings.Split(query, ",")
+	var ids []uint32
+	for _, idStr := range idStrs {
+		id, err := quac.ParseID(idStr)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == len(idStrs) {
+		for _, id := range ids {
+			subset := all.InRange(id, id)
+			if len(subset) == 0 {
+				log.Fatalf("nothing found at id %v", id)
+			}
+			ideas = append(ideas, subset[0])
+		}
+		return ideas
+	}
+
+	ideas = all.WithTags(idea.ParseClumpedTags(query))
+	sort.Slice(ideas, func(i, j int) bool { return ideas[i].Id < ideas[j].Id })
+	return ideas
+}
+
 func ListAllFilesByLocation() {
 	ideas := quac.GetAllIdeas()
 	if len(ideas) == 0 {
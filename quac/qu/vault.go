@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/rigelrozanski/thranch/quac"
+)
+
+// commands which may be used while the vault is locked
+var lockedVaultCommands = map[string]bool{
+	keyHelp1:      true,
+	keyHelp2:      true,
+	keyUnlock:     true,
+	keyLock:       true,
+	keyBackup:     true,
+	keyIdBlocks:   true,
+	keyVaultWatch: true,
+}
+
+func Unlock() {
+	created, err := quac.UnlockVault()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if created {
+		fmt.Println("vault created, the plaintext ideas have been wiped from the ranch")
+	}
+	if err := startVaultWatch(); err != nil {
+		fmt.Printf("could not start the vault watcher, the vault is only locked "+
+			"by the next command after timing out: %v\n", err)
+	}
+	fmt.Printf("vault unlocked to %v until %v after the last command\n",
+		quac.VaultUnlockedDir, quac.VaultTimeout)
+}
+
+// start the watcher in the background which locks the vault once the session
+// times out
+func startVaultWatch() error {
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// ignored signals are inherited, so the watcher outlives the terminal
+	signal.Ignore(syscall.SIGHUP)
+	cmd := exec.Command(bin, keyVaultWatch)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// VaultWatch is run by the watcher in the background
+func VaultWatch() {
+	signal.Ignore(syscall.SIGHUP, syscall.SIGINT)
+	if err := quac.WatchVaultSession(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func Lock() {
+	if err := quac.LockVault(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("vault locked")
+}
@@ -0,0 +1,90 @@
+package quac
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// create a new typed relation between two existing ideas
+func Relate(from uint32, relType string, to uint32) error {
+	rel, err := idea.NewRelation(from, relType, to)
+	if err != nil {
+		return err
+	}
+	for _, id := range []uint32{from, to} {
+		if GetFilenameByID(id) == "" {
+			return fmt.Errorf("nothing found at id %v", idea.IdStr(id))
+		}
+	}
+	rels := idea.GetAllRelations()
+	if rels.Has(rel) {
+		return fmt.Errorf("relation already exists: %v", rel)
+	}
+	idea.WriteRelations(append(rels, rel))
+	return nil
+}
+
+// remove the relation between two ideas, if no relation type is provided
+// all relations from the first to the second idea are removed
+func Unrelate(from uint32, relType string, to uint32) error {
+	if relType != "" {
+		if err := idea.ValidateRelationType(relType); err != nil {
+			return err
+		}
+	}
+	rels := idea.GetAllRelations()
+	remaining := rels.Without(from, relType, to)
+	if len(remaining) == len(rels) {
+		return fmt.Errorf("no relation found from %v to %v",
+			idea.IdStr(from), idea.IdStr(to))
+	}
+	idea.WriteRelations(remaining)
+	return nil
+}
+
+// Display all the relations to and from an idea
+func GetRelations(id uint32) (compiled string) {
+	for _, rel := range idea.GetAllRelations().Involving(id) {
+		otherId := rel.To
+		if rel.To == id {
+			otherId = rel.From
+		}
+		otherFn := GetFilenameByID(otherId)
+		if otherFn == "" {
+			otherFn = "(missing)"
+		}
+		compiled += fmt.Sprintf("%v %v %v\t%v\n", idea.IdStr(rel.From),
+			rel.Type, idea.IdStr(rel.To), otherFn)
+	}
+	return compiled
+}
+
+// export all the relations involving the ideas as csv, one relation per
+// record, for building a map of understanding
+func ExportRelationsCSV(idears idea.Ideas, w io.Writer) error {
+	tagsById := make(map[uint32]string)
+	for _, idear := range GetAllIdeas() {
+		tagsById[idear.Id] = idear.GetClumpedTags()
+	}
+
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"from_id", "from_tags", "relation", "to_id", "to_tags"})
+	if err != nil {
+		return err
+	}
+	for _, rel := range idea.GetAllRelations().InvolvingAny(idears) {
+		err := cw.Write([]string{
+			idea.IdStr(rel.From), tagsById[rel.From],
+			rel.Type,
+			idea.IdStr(rel.To), tagsById[rel.To],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,116 @@
+package quac
+
+import (
+	"io/ioutil"
+	"log"
+	"sort"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// IdRemap maps the ids of ideas from elsewhere (such as an import) to the ids
+// which they are given within this ranch
+type IdRemap map[uint32]uint32
+
+// AllocateIds allocates an id within this ranch for each of the foreign ids.
+// If preserve is true foreign ids are kept wherever they are not already in
+// use within this ranch (including the trash can).
+func AllocateIds(foreignIds []uint32, preserve bool) IdRemap {
+	remap := make(IdRemap)
+
+	inUse := make(map[uint32]bool)
+	for _, idear := range GetAllIdeas() {
+		inUse[idear.Id] = true
+	}
+	trashFiles, err := ioutil.ReadDir(TrashCanDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range trashFiles {
+		inUse[idea.NewIdeaFromFilename(file.Name(), false).Id] = true
+	}
+
+	sorted := make([]uint32, len(foreignIds))
+	copy(sorted, foreignIds)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	if preserve {
+		for _, id := range sorted {
+			if id == 0 || inUse[id] {
+				continue
+			}
+			remap[id] = id
+			inUse[id] = true
+			idea.EnsureIDCounterAtLeast(id)
+		}
+	}
+	for _, id := range sorted {
+		if _, found := remap[id]; found {
+			continue
+		}
+		newId := idea.GetNextID()
+		for inUse[newId] {
+			idea.IncrementID()
+			newId = idea.GetNextID()
+		}
+		idea.IncrementID()
+		remap[id] = newId
+		inUse[newId] = true
+	}
+	return remap
+}
+
+// the remapped id, ids which are not within the remap are unchanged
+func (remap IdRemap) Id(id uint32) uint32 {
+	if newId, found := remap[id]; found {
+		return newId
+	}
+	return id
+}
+
+// the remapped ids, ids which are not within the remap are unchanged as they
+// refer to ideas shared with this ranch (such as a synced ranch)
+func (remap IdRemap) Ids(ids []uint32) []uint32 {
+	out := make([]uint32, len(ids))
+	for i, id := range ids {
+		out[i] = remap.Id(id)
+	}
+	return out
+}
+
+// the remapped ids, ids which are not within the remap are dropped as they
+// refer to ideas which only exist elsewhere (such as outside of an import)
+func (remap IdRemap) ForeignIds(ids []uint32) []uint32 {
+	var out []uint32
+	for _, id := range ids {
+		if newId, found := remap[id]; found {
+			out = append(out, newId)
+		}
+	}
+	return out
+}
+
+// rewrite all the [[id]] links within the content which are within the remap,
+// all other links are unchanged as they refer to ideas shared with this ranch
+func (remap IdRemap) Links(content string) string {
+	return idea.ReplaceLinks(content, func(id uint32, linkText string) string {
+		newId, found := remap[id]
+		if !found || newId == id {
+			return linkText
+		}
+		return "[[" + idea.IdStr(newId) + "]]"
+	})
+}
+
+// rewrite all the [[id]] links within the content which are within the remap,
+// all other links refer to ideas which only exist elsewhere and are left as
+// the plain id so they don't link to an unrelated idea of this ranch
+func (remap IdRemap) ForeignLinks(content string) string {
+	return idea.ReplaceLinks(content, func(id uint32, linkText string) string {
+		newId, found := remap[id]
+		if !found {
+			return idea.IdStr(id)
+		}
+		return "[[" + idea.IdStr(newId) + "]]"
+	})
+}
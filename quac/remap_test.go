@@ -0,0 +1,24 @@
+package quac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIdRemapUnmapped(t *testing.T) {
+	remap := IdRemap{1: 4, 2: 5}
+	content := "see [[000001]] and [[000003]]"
+
+	if got := remap.Links(content); got != "see [[000004]] and [[000003]]" {
+		t.Errorf("unexpected links: %q", got)
+	}
+	if got := remap.ForeignLinks(content); got != "see [[000004]] and 000003" {
+		t.Errorf("unexpected foreign links: %q", got)
+	}
+	if got := remap.Ids([]uint32{2, 3}); !reflect.DeepEqual(got, []uint32{5, 3}) {
+		t.Errorf("unexpected ids: %v", got)
+	}
+	if got := remap.ForeignIds([]uint32{2, 3}); !reflect.DeepEqual(got, []uint32{5}) {
+		t.Errorf("unexpected foreign ids: %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package quac
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// lines beginning with the split marker start a new idea, any tags following
+// the marker are added to the tags inherited from the original idea
+const SplitMarker = "--- tags:"
+
+// a piece of a split idea
+type SplitPiece struct {
+	ClumpedTags string // additional tags
+	Content     string
+}
+
+// split the content at each line beginning with the SplitMarker, the content
+// before the first marker is the first piece
+func SplitOnMarkers(content string) (pieces []SplitPiece) {
+	piece := SplitPiece{}
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if !strings.HasPrefix(line, SplitMarker) {
+			lines = append(lines, line)
+			continue
+		}
+		piece.Content = strings.Join(lines, "\n")
+		pieces = append(pieces, piece)
+
+		piece = SplitPiece{
+			ClumpedTags: strings.TrimSpace(strings.TrimPrefix(line, SplitMarker)),
+		}
+		lines = []string{}
+	}
+	piece.Content = strings.Join(lines, "\n")
+	return append(pieces, piece)
+}
+
+// open the idea in the editor and split it into new ideas at the split
+// markers. Each new idea consumes the original idea.
+func SplitByID(id uint32) (newFilepaths []string, err error) {
+	origIdea := GetIdeaByID(id, true)
+	if origIdea.Cycle != CycleAlive {
+		return nil, fmt.Errorf("cannot split non-alive idea %v", origIdea.Filename)
+	}
+	if !origIdea.IsText() {
+		return nil, fmt.Errorf("cannot split non-text idea %v", origIdea.Filename)
+	}
+
+	err = ioutil.WriteFile(WorkingSplitFile, origIdea.GetContent(), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	EditText(WorkingSplitFile)
+	splitBz, err := ioutil.ReadFile(WorkingSplitFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pieces := SplitOnMarkers(string(splitBz))
+	if len(pieces) < 2 {
+		return nil, errors.New("no split markers found, nothing was split")
+	}
+
+	// empty pieces are skipped, the original is left as is if all are empty
+	var nonEmpty []SplitPiece
+	for _, piece := range pieces {
+		if strings.TrimSpace(piece.Content) != "" {
+			nonEmpty = append(nonEmpty, piece)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, errors.New("every piece is empty, nothing was split")
+	}
+
+	for _, piece := range nonEmpty {
+		newFilename := ReserveCopyFilename(origIdea.Filename, piece.ClumpedTags)
+		newIdea := idea.NewIdeaFromFilename(newFilename, false)
+		newIdea.ConsumesIds = append(newIdea.ConsumesIds, origIdea.Id)
+		newIdea.Edited = idea.TodayDate()
+		(&newIdea).UpdateFilename()
+
+		WriteIdea(newIdea.Filename, strings.TrimRight(piece.Content, "\n")+"\n")
+		newFilepaths = append(newFilepaths, newIdea.Path())
+	}
+
+	origIdea.SetConsumed()
+	return newFilepaths, nil
+}
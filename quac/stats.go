@@ -11,7 +11,7 @@ func GetStats() {
 	idears := idea.GetAllIdeas()
 	ncidears := idea.GetAllIdeasNonConsuming()
 	imgidears := ncidears.WithImage()
-	wot, _ := idea.NewTagWithout("DNT", "")
+	wot, _ := idea.NewTagWithout(idea.WithoutKeyword, "DNT")
 	untranscribed := imgidears.WithTags(wot)
 	utags := ncidears.UniqueTags()
 
@@ -0,0 +1,710 @@
+package quac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// Two ranches are synchronized with a three-way merge against the state of
+// both ranches at their last sync, which is recorded within the sync directory
+// of each ranch. Ideas are identified across ranches by their id. The id
+// blocks reserved by each device are merged as a union, and a new block is
+// reserved for this device when its blocks are running out.
+
+const (
+	syncLocal  = 0
+	syncRemote = 1
+
+	SyncPreferLocal  = "local"
+	SyncPreferRemote = "remote"
+
+	syncDirName          = "sync"
+	syncConflictsDirName = "conflicts"
+	syncRelationPrefix   = "rel\t"
+)
+
+var syncSideNames = [2]string{"local", "remote"}
+
+type SyncOptions struct {
+	DryRun bool   // only report what would be done
+	Prefer string // resolve content conflicts by preferring this side
+}
+
+// an idea file within one of the ranches being synchronized
+type syncFile struct {
+	Filename string
+	Hash     string
+	path     string // file holding the content
+	content  []byte // content which overrides the file at path
+}
+
+func (f syncFile) idea() idea.Idea {
+	return idea.NewIdeaFromFilename(f.Filename, false)
+}
+
+func (f syncFile) read() ([]byte, error) {
+	if f.content != nil {
+		return f.content, nil
+	}
+	return ioutil.ReadFile(f.path)
+}
+
+// a ranch being synchronized
+type syncSide struct {
+	dir     string
+	files   map[uint32]syncFile
+	onDisk  map[string]uint32 // id of each idea file as loaded
+	counter uint32
+	rels    []string
+	blocks  []idea.IdBlock
+}
+
+func loadSyncSide(dir string) (side syncSide, err error) {
+	side = syncSide{dir: dir, files: make(map[uint32]syncFile), onDisk: make(map[string]uint32)}
+	fis, err := ioutil.ReadDir(path.Join(dir, "ideas"))
+	if err != nil {
+		return side, err
+	}
+	for _, fi := range fis {
+		ext := path.Ext(fi.Name())
+		if fi.IsDir() || ext == ".swp" || ext == ".vim" {
+			continue
+		}
+		p := path.Join(dir, "ideas", fi.Name())
+		bz, err := ioutil.ReadFile(p)
+		if err != nil {
+			return side, err
+		}
+		id := idea.NewIdeaFromFilename(fi.Name(), false).Id
+		if _, found := side.files[id]; found {
+			return side, fmt.Errorf("multiple ideas with id %v within %v", idea.IdStr(id), dir)
+		}
+		side.files[id] = syncFile{Filename: fi.Name(), Hash: syncHash(bz), path: p}
+		side.onDisk[fi.Name()] = id
+	}
+
+	lines, err := cmn.ReadLines(path.Join(dir, "config"))
+	if err != nil || len(lines) == 0 {
+		return side, fmt.Errorf("bad id counter within %v", dir)
+	}
+	side.counter, err = idea.ParseIDNoLogLast(lines[0])
+	if err != nil {
+		return side, err
+	}
+	side.rels, err = syncRelationLines(path.Join(dir, "relations"))
+	if err != nil {
+		return side, err
+	}
+	side.blocks, err = idea.ReadIdBlocks(path.Join(dir, "id_blocks"))
+	return side, err
+}
+
+func syncRelationLines(relationsPath string) (lines []string, err error) {
+	if !cmn.FileExists(relationsPath) {
+		return lines, nil
+	}
+	all, err := cmn.ReadLines(relationsPath)
+	for _, line := range all {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines, err
+}
+
+func syncHash(bz []byte) string {
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:])
+}
+
+// the sync state file within the ranch at dir for the ranch at peerDir
+func syncStatePath(dir, peerDir string) string {
+	return path.Join(dir, syncDirName, strings.Replace(strings.Trim(peerDir, "/"), "/", "_", -1))
+}
+
+// read the state of the last sync, each idea is recorded on its own line as
+// "id<tab>hash<tab>filename" and each relation as "rel<tab>relation"
+func readSyncState(statePath string) (base map[uint32]syncFile, rels []string, err error) {
+	base = make(map[uint32]syncFile)
+	if !cmn.FileExists(statePath) {
+		return base, rels, nil
+	}
+	lines, err := cmn.ReadLines(statePath)
+	if err != nil {
+		return base, rels, err
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, syncRelationPrefix) {
+			rels = append(rels, strings.TrimPrefix(line, syncRelationPrefix))
+			continue
+		}
+		split := strings.SplitN(line, "\t", 3)
+		if len(split) != 3 {
+			continue
+		}
+		id, err := idea.ParseIDNoLogLast(split[0])
+		if err != nil {
+			return base, rels, fmt.Errorf("bad sync state line: %v", line)
+		}
+		base[id] = syncFile{Hash: split[1], Filename: split[2]}
+	}
+	return base, rels, nil
+}
+
+func writeSyncState(statePath string, base map[uint32]syncFile, rels []string) error {
+	var ids []uint32
+	for id := range base {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var lines []string
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("%v\t%v\t%v", idea.IdStr(id), base[id].Hash, base[id].Filename))
+	}
+	for _, rel := range rels {
+		lines = append(lines, syncRelationPrefix+rel)
+	}
+	err := os.MkdirAll(path.Dir(statePath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	return cmn.WriteLines(lines, statePath)
+}
+
+// the synchronization of two ranches
+type syncPlan struct {
+	sides          [2]syncSide
+	base           map[uint32]syncFile
+	desired        [2]map[uint32]syncFile // ideas of each ranch after the sync
+	trash          [2]map[uint32]bool     // ids to move into the trash of each ranch
+	conflictCopies [2]map[uint32]syncFile // content of the other side of conflicts
+	rels           []string
+	blocks         []idea.IdBlock
+	counter        uint32
+	report         []string
+	conflicts      []string
+}
+
+// Sync merges the ranch at otherDir with this ranch such that afterwards both
+// ranches contain the same ideas. Ideas renamed (such as by editing tags) on
+// either side have their filenames merged, content edited on only one side is
+// taken from that side, and ideas created independently within each ranch
+// with the same id are renumbered. Content edited differently on both sides
+// is reported as a conflict and left as is within each ranch (a copy of the
+// other side is saved within the conflicts directory) unless a side is
+// preferred within the options.
+func Sync(otherDir string, opts SyncOptions) (report, conflicts []string, err error) {
+	if opts.Prefer != "" && opts.Prefer != SyncPreferLocal && opts.Prefer != SyncPreferRemote {
+		return report, conflicts, fmt.Errorf("can only prefer %v or %v", SyncPreferLocal, SyncPreferRemote)
+	}
+	if VaultMode {
+		return report, conflicts, fmt.Errorf("ranches within vault mode cannot be synced")
+	}
+	localDir, err := filepath.Abs(QuDir)
+	if err != nil {
+		return report, conflicts, err
+	}
+	otherDir, err = filepath.Abs(otherDir)
+	if err != nil {
+		return report, conflicts, err
+	}
+	if localDir == otherDir {
+		return report, conflicts, fmt.Errorf("cannot sync a ranch with itself")
+	}
+
+	var p syncPlan
+	p.sides[syncLocal], err = loadSyncSide(localDir)
+	if err != nil {
+		return report, conflicts, err
+	}
+	p.sides[syncRemote], err = loadSyncSide(otherDir)
+	if err != nil {
+		return report, conflicts, err
+	}
+	var baseRels []string
+	p.base, baseRels, err = readSyncState(syncStatePath(localDir, otherDir))
+	if err != nil {
+		return report, conflicts, err
+	}
+	for i := range p.desired {
+		p.desired[i] = make(map[uint32]syncFile)
+		p.trash[i] = make(map[uint32]bool)
+		p.conflictCopies[i] = make(map[uint32]syncFile)
+	}
+
+	var resolved []string
+	p.blocks, resolved = idea.MergeIdBlocks(p.sides[syncLocal].blocks, p.sides[syncRemote].blocks)
+	p.report = append(p.report, resolved...)
+	p.renumber()
+	if idea.DeviceName != "" {
+		// both ranches are up to date here so a block reserved can't overlap
+		var reserved bool
+		p.blocks, reserved = idea.EnsureDeviceBlock(p.blocks, p.counter)
+		if reserved {
+			b := p.blocks[len(p.blocks)-1]
+			p.reportf("reserved id block %v-%v for device %v",
+				idea.IdStr(b.Start), idea.IdStr(b.End), b.Device)
+		}
+	}
+	for _, id := range p.allIds() {
+		p.mergeIdea(id, opts.Prefer)
+	}
+	p.mergeRelations(baseRels)
+	for side := range p.sides {
+		if !p.blocksEqual(side) {
+			p.reportf("id blocks updated on %v", syncSideNames[side])
+		}
+	}
+
+	if opts.DryRun {
+		return p.report, p.conflicts, nil
+	}
+	err = p.apply()
+	if err != nil {
+		return p.report, p.conflicts, err
+	}
+	for _, pair := range [][2]string{{localDir, otherDir}, {otherDir, localDir}} {
+		err = writeSyncState(syncStatePath(pair[0], pair[1]), p.base, p.rels)
+		if err != nil {
+			return p.report, p.conflicts, err
+		}
+	}
+	return p.report, p.conflicts, nil
+}
+
+func (p *syncPlan) allIds() (ids []uint32) {
+	seen := make(map[uint32]bool)
+	for _, files := range []map[uint32]syncFile{p.base, p.sides[syncLocal].files, p.sides[syncRemote].files} {
+		for id := range files {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (p *syncPlan) reportf(format string, args ...interface{}) {
+	p.report = append(p.report, fmt.Sprintf(format, args...))
+}
+
+// ideas created independently within each ranch since the last sync with the
+// same id are renumbered within the remote ranch, along with all references to
+// them by other new ideas of the remote ranch
+func (p *syncPlan) renumber() {
+	local, remote := p.sides[syncLocal], p.sides[syncRemote]
+
+	// the counter is the highest id either ranch has used
+	used := make(map[uint32]bool)
+	p.counter = local.counter
+	if remote.counter > p.counter {
+		p.counter = remote.counter
+	}
+	for _, id := range p.allIds() {
+		used[id] = true
+		if id > p.counter {
+			p.counter = id
+		}
+	}
+	for _, dir := range []string{local.dir, remote.dir} {
+		fis, _ := ioutil.ReadDir(path.Join(dir, "trash"))
+		for _, fi := range fis {
+			id := idea.NewIdeaFromFilename(fi.Name(), false).Id
+			used[id] = true
+			if id > p.counter {
+				p.counter = id
+			}
+		}
+	}
+
+	remap := make(IdRemap)
+	for _, id := range p.allIds() {
+		l, inL := local.files[id]
+		r, inR := remote.files[id]
+		if _, inB := p.base[id]; inB || !inL || !inR || l.Hash == r.Hash {
+			continue
+		}
+		remap[id] = p.nextID(used)
+		p.reportf("renumbered remote %v to %v (independently created with the same id)",
+			idea.IdStr(id), idea.IdStr(remap[id]))
+	}
+	if len(remap) == 0 {
+		return
+	}
+
+	renumbered := make(map[uint32]syncFile)
+	for id, f := range remote.files {
+		if _, inB := p.base[id]; inB {
+			renumbered[id] = f
+			continue
+		}
+		idear := f.idea()
+		idear.Id = remap.Id(idear.Id)
+		idear.ConsumesIds = remap.Ids(idear.ConsumesIds)
+		(&idear).UpdateFilename()
+		nf := syncFile{Filename: idear.Filename, Hash: f.Hash, path: f.path}
+		if idear.IsText() {
+			bz, err := f.read()
+			if err == nil {
+				nf.content = []byte(remap.Links(string(bz)))
+				nf.Hash = syncHash(nf.content)
+			}
+		}
+		renumbered[idear.Id] = nf
+	}
+
+	p.sides[syncRemote].files = renumbered
+	for i, line := range p.sides[syncRemote].rels {
+		if rel, err := idea.ParseRelation(line); err == nil {
+			rel.From, rel.To = remap.Id(rel.From), remap.Id(rel.To)
+			p.sides[syncRemote].rels[i] = rel.String()
+		}
+	}
+}
+
+// the next id for a renumbered idea, allocated from the block of this device
+// when a device is configured otherwise above everything either ranch has used
+func (p *syncPlan) nextID(used map[uint32]bool) uint32 {
+	if idea.DeviceName == "" {
+		p.counter++
+		return p.counter
+	}
+	for {
+		var id uint32
+		p.blocks, id = idea.AllocateDeviceID(p.blocks, p.counter)
+		if used[id] {
+			continue
+		}
+		used[id] = true
+		if id > p.counter {
+			p.counter = id
+		}
+		return id
+	}
+}
+
+// determine the merged state of a single idea
+func (p *syncPlan) mergeIdea(id uint32, prefer string) {
+	b, inB := p.base[id]
+	l, inL := p.sides[syncLocal].files[id]
+	r, inR := p.sides[syncRemote].files[id]
+	idStr := idea.IdStr(id)
+
+	switch {
+	case !inL && !inR:
+		delete(p.base, id)
+
+	case inL != inR:
+		side, f := syncLocal, l
+		if inR {
+			side, f = syncRemote, r
+		}
+		other := 1 - side
+		switch {
+		case !inB:
+			p.reportf("%v to %v: %v", syncVerb(other), syncSideNames[other], f.Filename)
+			p.set(id, f)
+		case f.Hash == b.Hash && f.Filename == b.Filename:
+			p.reportf("trashed on %v (deleted on %v): %v", syncSideNames[side],
+				syncSideNames[other], f.Filename)
+			p.trash[side][id] = true
+			delete(p.base, id)
+		default:
+			p.reportf("restored to %v (deleted on %v but edited on %v): %v",
+				syncSideNames[other], syncSideNames[other], syncSideNames[side], f.Filename)
+			p.set(id, f)
+		}
+
+	case l.Hash == r.Hash && l.Filename == r.Filename:
+		p.set(id, l)
+
+	default:
+		contentSide := -1
+		switch {
+		case l.Hash == r.Hash:
+			contentSide = syncLocal
+		case inB && l.Hash == b.Hash:
+			contentSide = syncRemote
+		case inB && r.Hash == b.Hash:
+			contentSide = syncLocal
+		case prefer == SyncPreferLocal:
+			contentSide = syncLocal
+		case prefer == SyncPreferRemote:
+			contentSide = syncRemote
+		}
+		if contentSide < 0 {
+			p.conflicts = append(p.conflicts, fmt.Sprintf(
+				"%v content edited on both sides\n\tlocal:  %v\n\tremote: %v", idStr, l.Filename, r.Filename))
+			p.desired[syncLocal][id] = l
+			p.desired[syncRemote][id] = r
+			p.conflictCopies[syncLocal][id] = r
+			p.conflictCopies[syncRemote][id] = l
+			return
+		}
+
+		files := [2]syncFile{l, r}
+		merged := files[contentSide]
+		if l.Filename != r.Filename {
+			merged.Filename = syncMergeFilenames(b, inB, l, r, contentSide)
+		}
+		if l.Hash != r.Hash {
+			p.reportf("%v content from %v", idStr, syncSideNames[contentSide])
+		}
+		for side, f := range files {
+			if f.Filename != merged.Filename {
+				p.reportf("renamed on %v: %v -> %v", syncSideNames[side], f.Filename, merged.Filename)
+			}
+		}
+		p.set(id, merged)
+	}
+}
+
+func syncVerb(toSide int) string {
+	if toSide == syncLocal {
+		return "pulled"
+	}
+	return "pushed"
+}
+
+// set the idea within both ranches and record it within the sync state
+func (p *syncPlan) set(id uint32, f syncFile) {
+	p.desired[syncLocal][id] = f
+	p.desired[syncRemote][id] = f
+	p.base[id] = syncFile{Filename: f.Filename, Hash: f.Hash}
+}
+
+// three-way merge of the metadata held within the filenames of an idea, the
+// extension follows the side whose content is kept
+func syncMergeFilenames(b syncFile, inB bool, l, r syncFile, contentSide int) string {
+	li, ri := l.idea(), r.idea()
+	if !inB {
+		// without a common base keep the most recently edited
+		if ri.Edited.After(li.Edited) {
+			return r.Filename
+		}
+		return l.Filename
+	}
+	bi := b.idea()
+
+	merged := li
+	if li.Cycle == bi.Cycle && li.Consumed.Equal(bi.Consumed) {
+		merged.Cycle, merged.Consumed = ri.Cycle, ri.Consumed
+	}
+	if li.Created.Equal(bi.Created) {
+		merged.Created = ri.Created
+	}
+	if ri.Edited.After(li.Edited) {
+		merged.Edited = ri.Edited
+	}
+	if contentSide == syncRemote {
+		merged.Ext, merged.Kind = ri.Ext, ri.Kind
+	}
+
+	// consumes ids and tags are merged as sets
+	var bIds, lIds, rIds []string
+	for _, ids := range []struct {
+		in  []uint32
+		out *[]string
+	}{{bi.ConsumesIds, &bIds}, {li.ConsumesIds, &lIds}, {ri.ConsumesIds, &rIds}} {
+		for _, id := range ids.in {
+			*ids.out = append(*ids.out, idea.IdStr(id))
+		}
+	}
+	merged.ConsumesIds = []uint32{}
+	for _, idStr := range syncMergeSets(bIds, lIds, rIds) {
+		id, _ := idea.ParseIDNoLogLast(idStr)
+		merged.ConsumesIds = append(merged.ConsumesIds, id)
+	}
+
+	tagsByStr := make(map[string]idea.Tag)
+	var bTags, lTags, rTags []string
+	for _, tags := range []struct {
+		in  []idea.Tag
+		out *[]string
+	}{{bi.Tags, &bTags}, {li.Tags, &lTags}, {ri.Tags, &rTags}} {
+		for _, tag := range tags.in {
+			tagsByStr[tag.String()] = tag
+			*tags.out = append(*tags.out, tag.String())
+		}
+	}
+	merged.Tags = []idea.Tag{}
+	for _, tagStr := range syncMergeSets(bTags, lTags, rTags) {
+		merged.Tags = append(merged.Tags, tagsByStr[tagStr])
+	}
+	if len(merged.Tags) == 0 {
+		merged.Tags = li.Tags
+	}
+	(&merged).UpdateFilename()
+	return merged.Filename
+}
+
+// three-way merge of sets: elements on both sides remain, elements added on
+// either side are added and elements removed on either side are removed.
+// The order of local followed by remote is kept.
+func syncMergeSets(base, local, remote []string) (merged []string) {
+	inBase, inLocal, inRemote := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	for _, el := range base {
+		inBase[el] = true
+	}
+	for _, el := range local {
+		inLocal[el] = true
+	}
+	for _, el := range remote {
+		inRemote[el] = true
+	}
+	added := make(map[string]bool)
+	for _, el := range append(append([]string{}, local...), remote...) {
+		if added[el] {
+			continue
+		}
+		if (inLocal[el] && inRemote[el]) || !inBase[el] {
+			merged = append(merged, el)
+			added[el] = true
+		}
+	}
+	return merged
+}
+
+func (p *syncPlan) mergeRelations(baseRels []string) {
+	p.rels = syncMergeSets(baseRels, p.sides[syncLocal].rels, p.sides[syncRemote].rels)
+	sort.Strings(p.rels)
+	for side := range p.sides {
+		if !p.relsEqual(side) {
+			p.reportf("relations updated on %v", syncSideNames[side])
+		}
+	}
+}
+
+func (p *syncPlan) relsEqual(side int) bool {
+	return strings.Join(syncSorted(p.sides[side].rels), "\n") == strings.Join(p.rels, "\n")
+}
+
+func (p *syncPlan) blocksEqual(side int) bool {
+	blocks := p.sides[side].blocks
+	if len(blocks) != len(p.blocks) {
+		return false
+	}
+	for i := range blocks {
+		if blocks[i] != p.blocks[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func syncSorted(in []string) []string {
+	out := append([]string{}, in...)
+	sort.Strings(out)
+	return out
+}
+
+// carry out the plan on both ranches, all content is read before anything
+// is written as content may move between ids
+func (p *syncPlan) apply() error {
+	type write struct {
+		path string
+		bz   []byte
+	}
+	var writes []write
+	var removes [][2]string
+	var trashes [][3]string // path, trash path and OCR draft path
+	for side := range p.sides {
+		s := p.sides[side]
+		ideasDir := path.Join(s.dir, "ideas")
+		wanted := make(map[string]bool)
+		for _, f := range p.desired[side] {
+			wanted[f.Filename] = true
+		}
+		for fn, id := range s.onDisk {
+			switch {
+			case wanted[fn]:
+			case p.trash[side][id]:
+				trashes = append(trashes, [3]string{path.Join(ideasDir, fn), path.Join(s.dir, "trash", fn),
+					path.Join(s.dir, "ocr", s.files[id].Hash+".txt")})
+			default:
+				removes = append(removes, [2]string{path.Join(ideasDir, fn), ""})
+			}
+		}
+		for id, f := range p.desired[side] {
+			// content rewritten by renumbering differs from the file on disk
+			if existing, found := s.files[id]; found && s.onDisk[f.Filename] == id &&
+				existing.Filename == f.Filename && existing.Hash == f.Hash && f.content == nil {
+				continue
+			}
+			bz, err := f.read()
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{path.Join(ideasDir, f.Filename), bz})
+		}
+
+		conflictsDir := path.Join(s.dir, syncDirName, syncConflictsDirName)
+		for id, f := range p.conflictCopies[side] {
+			bz, err := f.read()
+			if err != nil {
+				return err
+			}
+			writes = append(writes, write{path.Join(conflictsDir, idea.IdStr(id)+"."+
+				syncSideNames[1-side]+path.Ext(f.Filename)), bz})
+		}
+	}
+
+	// new files are written before old files are removed so that an
+	// interrupted sync never loses an idea, at worst it leaves a duplicate
+	for _, w := range writes {
+		if err := os.MkdirAll(path.Dir(w.path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(w.path, w.bz, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	for _, t := range trashes {
+		if err := os.MkdirAll(path.Dir(t[1]), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.Remove(t[2]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(t[0], t[1]); err != nil {
+			return err
+		}
+	}
+	for _, r := range removes {
+		if err := os.Remove(r[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for side := range p.sides {
+		s := p.sides[side]
+		if !p.relsEqual(side) {
+			if err := cmn.WriteLines(p.rels, path.Join(s.dir, "relations")); err != nil {
+				return err
+			}
+		}
+		if !p.blocksEqual(side) {
+			if err := idea.WriteIdBlocksTo(path.Join(s.dir, "id_blocks"), p.blocks); err != nil {
+				return err
+			}
+		}
+		if s.counter < p.counter {
+			if err := cmn.WriteLines([]string{idea.IdStr(p.counter)}, path.Join(s.dir, "config")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package quac
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// create a ranch for syncing holding the ideas (filename to content)
+func setupSyncRanch(t *testing.T, counter uint32, ideas map[string]string) (dir string) {
+	dir, err := ioutil.TempDir("", "sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"ideas", "trash"} {
+		if err := os.MkdirAll(path.Join(dir, sub), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "config"), []byte(idea.IdStr(counter)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for fn, content := range ideas {
+		writeSyncTestFile(t, path.Join(dir, "ideas", fn), content)
+	}
+	return dir
+}
+
+func writeSyncTestFile(t *testing.T, fp, content string) {
+	if err := ioutil.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the filenames and content of the ideas within the directory of a ranch
+func readSyncTestDir(t *testing.T, dir string) map[string]string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, fi := range fis {
+		bz, err := ioutil.ReadFile(path.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[fi.Name()] = string(bz)
+	}
+	return files
+}
+
+func syncTestRun(t *testing.T, local, remote string) (report, conflicts []string) {
+	QuDir = local
+	report, conflicts, err := Sync(remote, SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report, conflicts
+}
+
+func TestSyncThreeWayMerge(t *testing.T) {
+	defer os.RemoveAll(setupWorkingTest(t))
+	const (
+		fn1        = "a,000001,2021-03-07,e2021-03-07,foo"
+		fn1Renamed = "a,000001,2021-03-07,e2021-03-07,foo,baz"
+		fn2        = "a,000002,2021-03-07,e2021-03-07,bar"
+		fn3        = "a,000003,2021-03-07,e2021-03-07,qux"
+	)
+	local := setupSyncRanch(t, 3, map[string]string{fn1: "one\n", fn3: "three\n"})
+	defer os.RemoveAll(local)
+	remote := setupSyncRanch(t, 2, map[string]string{fn2: "two\n"})
+	defer os.RemoveAll(remote)
+
+	// the first sync has no base so each side receives the other's ideas
+	_, conflicts := syncTestRun(t, local, remote)
+	if len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+	expected := map[string]string{fn1: "one\n", fn2: "two\n", fn3: "three\n"}
+	for _, dir := range []string{local, remote} {
+		if got := readSyncTestDir(t, path.Join(dir, "ideas")); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("after the first sync %v holds %v", dir, got)
+		}
+	}
+
+	// content edited on one side and renamed on the other is merged, a
+	// deletion of an unchanged idea is carried over as a trashing and content
+	// edited on both sides is a conflict
+	writeSyncTestFile(t, path.Join(local, "ideas", fn1), "one edited\n")
+	writeSyncTestFile(t, path.Join(local, "ideas", fn3), "three local\n")
+	if err := os.Rename(path.Join(remote, "ideas", fn1), path.Join(remote, "ideas", fn1Renamed)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path.Join(remote, "ideas", fn2)); err != nil {
+		t.Fatal(err)
+	}
+	writeSyncTestFile(t, path.Join(remote, "ideas", fn3), "three remote\n")
+
+	_, conflicts = syncTestRun(t, local, remote)
+	if len(conflicts) != 1 {
+		t.Fatalf("expected a single conflict, got %v", conflicts)
+	}
+	for _, side := range []struct {
+		dir, fn3Content string
+	}{
+		{local, "three local\n"},
+		{remote, "three remote\n"},
+	} {
+		expected := map[string]string{fn1Renamed: "one edited\n", fn3: side.fn3Content}
+		if got := readSyncTestDir(t, path.Join(side.dir, "ideas")); !reflect.DeepEqual(got, expected) {
+			t.Errorf("after the second sync %v holds %v", side.dir, got)
+		}
+	}
+	trashed := readSyncTestDir(t, path.Join(local, "trash"))
+	if !reflect.DeepEqual(trashed, map[string]string{fn2: "two\n"}) {
+		t.Errorf("expected %v to be trashed locally, got %v", fn2, trashed)
+	}
+	copies := readSyncTestDir(t, path.Join(local, syncDirName, syncConflictsDirName))
+	if !reflect.DeepEqual(copies, map[string]string{"000003.remote": "three remote\n"}) {
+		t.Errorf("unexpected conflict copies: %v", copies)
+	}
+}
+
+func TestSyncRenumber(t *testing.T) {
+	defer os.RemoveAll(setupWorkingTest(t))
+	defer func(name string) { idea.DeviceName = name }(idea.DeviceName)
+	idea.DeviceName = ""
+
+	local := setupSyncRanch(t, 1, map[string]string{
+		"a,000001,2021-03-07,e2021-03-07,foo": "local\n",
+	})
+	defer os.RemoveAll(local)
+	remote := setupSyncRanch(t, 2, map[string]string{
+		"a,000001,2021-03-07,e2021-03-07,bar": "remote\n",
+		"a,000002,2021-03-07,e2021-03-07,baz": "see [[000001]]\n",
+	})
+	defer os.RemoveAll(remote)
+
+	// the remote idea created with the same id is renumbered above both
+	// counters along with the links to it
+	_, conflicts := syncTestRun(t, local, remote)
+	if len(conflicts) > 0 {
+		t.Fatalf("unexpected conflicts: %v", conflicts)
+	}
+	expected := map[string]string{
+		"a,000001,2021-03-07,e2021-03-07,foo": "local\n",
+		"a,000002,2021-03-07,e2021-03-07,baz": "see [[000003]]\n",
+		"a,000003,2021-03-07,e2021-03-07,bar": "remote\n",
+	}
+	for _, dir := range []string{local, remote} {
+		if got := readSyncTestDir(t, path.Join(dir, "ideas")); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%v holds %v", dir, got)
+		}
+		lines, err := ioutil.ReadFile(path.Join(dir, "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(lines) != "000003\n" {
+			t.Errorf("expected the counter of %v to be 000003, got %q", dir, lines)
+		}
+	}
+}
+
+func TestSyncMergeSets(t *testing.T) {
+	merged := syncMergeSets([]string{"a", "b"}, []string{"a", "b", "c"}, []string{"b"})
+	sort.Strings(merged)
+	if !reflect.DeepEqual(merged, []string{"b", "c"}) {
+		t.Errorf("unexpected merge: %v", merged)
+	}
+}
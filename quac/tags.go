@@ -47,9 +47,7 @@ func MultiOpenByTags(tags []idea.Tag, forceSplitView bool) {
 		Open(singleReturn)
 		return
 	}
-	origBzFN, origBzContent := GetOrigWorkingFileBytes()
-	OpenTextSplit(WorkingFnsFile, WorkingContentFile, maxFNLen)
-	SaveFromWorkingFiles(origBzFN, origBzContent)
+	EditWorkingFiles(maxFNLen)
 }
 
 func MultiOpenByRange(startId, endId uint32, forceSplitView bool) {
@@ -65,7 +63,5 @@ func MultiOpenByRange(startId, endId uint32, forceSplitView bool) {
 		Open(singleReturn)
 		return
 	}
-	origBzFN, origBzContent := GetOrigWorkingFileBytes()
-	OpenTextSplit(WorkingFnsFile, WorkingContentFile, maxFNLen)
-	SaveFromWorkingFiles(origBzFN, origBzContent)
+	EditWorkingFiles(maxFNLen)
 }
@@ -0,0 +1,326 @@
+package quac
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	_ "golang.org/x/image/tiff"
+	"golang.org/x/term"
+)
+
+// image-renderer= within the thranch config, one of auto (the default, also
+// when blank), kitty, sixel, iterm2 or halfblock. Any other value is run as a command
+// with the image path appended (for example "kitty +kitten icat").
+var ImageRenderer string
+
+// image renderers
+const (
+	RendererAuto      = "auto"
+	RendererKitty     = "kitty"
+	RendererSixel     = "sixel"
+	RendererITerm2    = "iterm2"
+	RendererHalfBlock = "halfblock"
+)
+
+// pixel size of a terminal cell, used to size the images sent to the
+// terminal. Taken from the terminal when it reports its pixel size.
+var (
+	cellPixelWidth  = 10
+	cellPixelHeight = 20
+)
+
+// DetectImageRenderer determines the image renderer from the environment of
+// the terminal. Within tmux (or screen) graphics protocols are not passed
+// through so half blocks are used.
+func DetectImageRenderer() string {
+	termEnv := os.Getenv("TERM")
+	termProgram := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("TMUX") != "" || strings.HasPrefix(termEnv, "screen") || strings.HasPrefix(termEnv, "tmux"):
+		return RendererHalfBlock
+	case os.Getenv("KITTY_WINDOW_ID") != "" || termEnv == "xterm-kitty" ||
+		termEnv == "xterm-ghostty" || termProgram == "ghostty":
+		return RendererKitty
+	case termProgram == "iTerm.app" || os.Getenv("LC_TERMINAL") == "iTerm2" ||
+		termProgram == "WezTerm":
+		return RendererITerm2
+	case strings.Contains(termEnv, "sixel") || strings.HasPrefix(termEnv, "foot") ||
+		termEnv == "mlterm" || termEnv == "yaft-256color" || termProgram == "mintty":
+		return RendererSixel
+	}
+	return RendererHalfBlock
+}
+
+// RenderImage draws the image at the path within the terminal, scaled to fit
+// the terminal size
+func RenderImage(w io.Writer, imagePath string) error {
+	renderer := strings.TrimSpace(ImageRenderer)
+	if renderer == "" || renderer == RendererAuto {
+		renderer = DetectImageRenderer()
+	}
+	switch renderer {
+	case RendererKitty, RendererSixel, RendererITerm2, RendererHalfBlock:
+	default:
+		fields := strings.Fields(renderer)
+		cmd := exec.Command(fields[0], append(fields[1:], imagePath)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
+
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("could not decode %v: %v", imagePath, err)
+	}
+
+	// leave a couple lines for the prompt below the image
+	cols, rows := 80, 24
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if c, r, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			cols, rows = c, r
+		}
+		// half blocks are sized by the cell rather than by pixels
+		w, h, ok := terminalCellPixels(int(os.Stdout.Fd()))
+		if ok && w > 0 && h > 0 && renderer != RendererHalfBlock {
+			cellPixelWidth, cellPixelHeight = w, h
+		}
+	}
+	imgW, imgH := img.Bounds().Dx(), img.Bounds().Dy()
+	if renderer == RendererHalfBlock {
+		// each cell holds a pixel across (rather than a cell's worth of pixels)
+		imgW, imgH = imgW*cellPixelWidth, imgH*cellPixelWidth
+	}
+	cols, rows = fitCells(imgW, imgH, cols, rows-2)
+
+	bw := bufio.NewWriter(w)
+	switch renderer {
+	case RendererKitty:
+		err = renderKitty(bw, img, cols, rows)
+	case RendererITerm2:
+		err = renderITerm2(bw, img, cols, rows)
+	case RendererSixel:
+		err = renderSixel(bw, scaleImage(img, cols*cellPixelWidth, rows*cellPixelHeight))
+	case RendererHalfBlock:
+		err = renderHalfBlock(bw, scaleImage(img, cols, rows*2))
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// fitCells returns the number of terminal cells (columns and rows) the image
+// occupies when scaled to fit within maxCols by maxRows, keeping its aspect
+// ratio. Images are not scaled up beyond their own size.
+func fitCells(imgWidth, imgHeight, maxCols, maxRows int) (cols, rows int) {
+	if imgWidth < 1 || imgHeight < 1 {
+		return 1, 1
+	}
+	if naturalCols := (imgWidth + cellPixelWidth - 1) / cellPixelWidth; maxCols > naturalCols {
+		maxCols = naturalCols
+	}
+	if maxCols < 1 {
+		maxCols = 1
+	}
+	if maxRows < 1 {
+		maxRows = 1
+	}
+
+	// cells are about twice as tall as they are wide
+	cols = maxCols
+	rows = (cols*imgHeight*cellPixelWidth/imgWidth + cellPixelHeight - 1) / cellPixelHeight
+	if rows > maxRows {
+		rows = maxRows
+		cols = rows * cellPixelHeight * imgWidth / (imgHeight * cellPixelWidth)
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return cols, rows
+}
+
+// scaleImage scales the image to the width and height, averaging the source
+// pixels which fall within each destination pixel
+func scaleImage(img image.Image, width, height int) *image.RGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcH/height
+		y1 := bounds.Min.Y + (y+1)*srcH/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcW/width
+			x1 := bounds.Min.X + (x+1)*srcW/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(b / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// kitty graphics protocol, the png is transmitted in chunks and placed
+// over the cells
+func renderKitty(w *bufio.Writer, img image.Image, cols, rows int) error {
+	bz, err := encodePNG(scaleImage(img, cols*cellPixelWidth, rows*cellPixelHeight))
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(bz)
+	const chunkSize = 4096
+	for i := 0; i < len(encoded); i += chunkSize {
+		end := i + chunkSize
+		more := 1
+		if end >= len(encoded) {
+			end, more = len(encoded), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\x1b_Ga=T,f=100,c=%v,r=%v,m=%v;%v\x1b\\", cols, rows, more, encoded[i:end])
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%v;%v\x1b\\", more, encoded[i:end])
+		}
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// iTerm2 inline images protocol (also supported by WezTerm)
+func renderITerm2(w *bufio.Writer, img image.Image, cols, rows int) error {
+	bz, err := encodePNG(scaleImage(img, cols*cellPixelWidth, rows*cellPixelHeight))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%v;width=%v;height=%v;preserveAspectRatio=1:%v\a\n",
+		len(bz), cols, rows, base64.StdEncoding.EncodeToString(bz))
+	return nil
+}
+
+// sixel graphics, the image is dithered to a 256 colour palette and drawn in
+// bands six pixels high
+func renderSixel(w *bufio.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+	width, height := bounds.Dx(), bounds.Dy()
+
+	fmt.Fprintf(w, "\x1bPq\"1;1;%v;%v", width, height)
+	for i, c := range paletted.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(w, "#%v;2;%v;%v;%v", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	row := make([]byte, width)
+	for band := 0; band < height; band += 6 {
+
+		// the colours used within the band
+		used := make(map[uint8]bool)
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[paletted.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y)] = true
+			}
+		}
+
+		first := true
+		for ci := 0; ci < len(paletted.Palette); ci++ {
+			if !used[uint8(ci)] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && band+dy < height; dy++ {
+					if paletted.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+band+dy) == uint8(ci) {
+						bits |= 1 << uint(dy)
+					}
+				}
+				row[x] = '?' + bits
+			}
+			if !first {
+				w.WriteByte('$') // return to the start of the band
+			}
+			first = false
+			fmt.Fprintf(w, "#%v", ci)
+			writeSixelRow(w, row)
+		}
+		w.WriteByte('-') // next band
+	}
+	w.WriteString("\x1b\\\n")
+	return nil
+}
+
+// write the sixel characters with repeats run length encoded
+func writeSixelRow(w *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%v%c", n, row[i])
+		} else {
+			w.Write(row[i:j])
+		}
+		i = j
+	}
+}
+
+// unicode upper half blocks with 24-bit colour, each cell holds two vertical
+// pixels (the foreground above the background)
+func renderHalfBlock(w *bufio.Writer, img *image.RGBA) error {
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := color.RGBA{}
+			if y+1 < bounds.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			fmt.Fprintf(w, "\x1b[38;2;%v;%v;%vm\x1b[48;2;%v;%v;%vm▀",
+				top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		w.WriteString("\x1b[0m\n")
+	}
+	return nil
+}
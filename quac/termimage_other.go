@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package quac
+
+// the pixel size of a terminal cell is not queried on this platform
+func terminalCellPixels(fd int) (width, height int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,90 @@
+package quac
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFitCells(t *testing.T) {
+	cases := []struct {
+		imgW, imgH, maxCols, maxRows int
+		cols, rows                   int
+	}{
+		{2000, 1000, 100, 50, 100, 25}, // limited by the width
+		{1000, 2000, 100, 20, 20, 20},  // limited by the height
+		{50, 50, 100, 50, 5, 3},        // not scaled up
+		{4000, 10, 100, 50, 100, 1},    // at least one row
+		{1000, 1000, 0, 0, 1, 1},       // no room
+		{0, 100, 100, 50, 1, 1},        // empty image
+	}
+	for _, c := range cases {
+		cols, rows := fitCells(c.imgW, c.imgH, c.maxCols, c.maxRows)
+		if cols != c.cols || rows != c.rows {
+			t.Errorf("fitCells(%v, %v, %v, %v) = %v, %v, expected %v, %v",
+				c.imgW, c.imgH, c.maxCols, c.maxRows, cols, rows, c.cols, c.rows)
+		}
+	}
+}
+
+func TestRenderHalfBlockAndSixel(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	img.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	img.SetRGBA(0, 1, color.RGBA{0, 0, 255, 255})
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := renderHalfBlock(w, img); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 { // three pixel rows in two lines of cells
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "\x1b[38;2;255;0;0m\x1b[48;2;0;0;255m▀") {
+		t.Errorf("unexpected first cell %q", lines[0])
+	}
+
+	buf.Reset()
+	if err := renderSixel(w, img); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	out := buf.String()
+	if !strings.HasPrefix(out, "\x1bPq\"1;1;2;3") || !strings.HasSuffix(out, "-\x1b\\\n") {
+		t.Errorf("unexpected sixel framing %q", out)
+	}
+}
+
+func TestRenderImageBlankRenderer(t *testing.T) {
+	file, err := ioutil.TempFile("", "render*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	// a blank renderer is auto, within tmux half blocks are detected
+	defer func(renderer string) { ImageRenderer = renderer }(ImageRenderer)
+	ImageRenderer = "  "
+	defer func(tmux string) { os.Setenv("TMUX", tmux) }(os.Getenv("TMUX"))
+	os.Setenv("TMUX", "/tmp/tmux")
+
+	var buf bytes.Buffer
+	if err := RenderImage(&buf, file.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "▀") {
+		t.Errorf("expected half blocks, got %q", buf.String())
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package quac
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// the pixel size of a cell of the terminal at fd, as reported by the terminal
+// through TIOCGWINSZ. Not every terminal reports its pixel size.
+func terminalCellPixels(fd int) (width, height int, ok bool) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Row == 0 || ws.Col == 0 || ws.Xpixel == 0 || ws.Ypixel == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Xpixel / ws.Col), int(ws.Ypixel / ws.Row), true
+}
@@ -0,0 +1,483 @@
+package quac
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	cmn "github.com/rigelrozanski/common"
+)
+
+// Within vault mode the ideas, trash and the files holding idea content are
+// only stored (within QuDir/vault) as encrypted blobs with random names
+// alongside an encrypted index of the path of each blob. Unlocking decrypts
+// them into a directory in memory (XDG_RUNTIME_DIR or /dev/shm) which all
+// commands then work against. Changes are encrypted back into the vault after
+// each command. The key for the session is kept within a session file until
+// the session times out (locked by a background watcher) or the vault is
+// locked, at which point the unlocked directory is wiped.
+const (
+	DefaultVaultTimeout = 15 * time.Minute
+
+	vaultIndexName = "index"
+	vaultSaltName  = "salt"
+	vaultBlobsDir  = "blobs"
+)
+
+var (
+	VaultMode        bool
+	VaultTimeout     = DefaultVaultTimeout
+	VaultDiskUnlock  bool   // allow unlocking onto disk without a memory backed directory
+	VaultDir         string // encrypted vault within QuDir
+	VaultUnlockedDir string // decrypted contents while unlocked
+	VaultSessionFile string
+	VaultLocked      bool // true if within vault mode and not unlocked
+
+	// whether the unlocked directory is memory backed
+	vaultInMemory bool
+
+	// whether initialization extends the vault session, false for the
+	// watcher which locks the vault once the session times out
+	ExtendVaultSession = true
+)
+
+// directories and files (relative to the unlocked directory) which are
+// stored within the vault
+var (
+	vaultDirs  = []string{"ideas", "trash", "ocr"}
+	vaultFiles = []string{"qu", "relations", "working_files", "working_content", "working_split",
+		"working_view", "working_snapshot"}
+)
+
+// entry of the vault index
+type vaultEntry struct {
+	blob     string
+	checksum string
+	relPath  string
+}
+
+// set the vault paths for the ranch, the unlocked directory is specific to
+// the ranch so that many ranches may be unlocked at once
+func setVaultPaths() {
+	VaultDir = path.Join(QuDir, "vault")
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" || !dirExists(runtimeDir) {
+		runtimeDir = "/dev/shm"
+	}
+	vaultInMemory = dirExists(runtimeDir)
+	if !vaultInMemory {
+		runtimeDir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(QuDir))
+	name := "thranch-" + hex.EncodeToString(sum[:6])
+	VaultUnlockedDir = path.Join(runtimeDir, name)
+	VaultSessionFile = path.Join(runtimeDir, name+".session")
+}
+
+// read the session key, if the session has timed out the vault is locked
+func vaultSessionKey() (key []byte, expired bool, err error) {
+	lines, err := cmn.ReadLines(VaultSessionFile)
+	if err != nil || len(lines) < 2 {
+		return nil, false, errors.New("no vault session")
+	}
+	key, err = hex.DecodeString(lines[0])
+	if err != nil {
+		return nil, false, err
+	}
+	expiry, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return nil, false, err
+	}
+	return key, time.Now().Unix() > expiry, nil
+}
+
+func writeVaultSession(key []byte) error {
+	expiry := time.Now().Add(VaultTimeout).Unix()
+	lines := []string{hex.EncodeToString(key), strconv.FormatInt(expiry, 10)}
+	err := ioutil.WriteFile(VaultSessionFile, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(VaultSessionFile, 0600)
+}
+
+// check the vault session on initialization, an active session is extended
+// by the timeout while a timed out session is locked
+func initVaultSession() {
+	key, expired, err := vaultSessionKey()
+	switch {
+	case err != nil || !dirExists(VaultUnlockedDir):
+		VaultLocked = true
+	case expired:
+		VaultLocked = true
+		if err := lockVault(key); err != nil {
+			panic(fmt.Sprintf("error locking the timed out vault session: %v", err))
+		}
+		fmt.Fprintln(os.Stderr, "vault session timed out and the vault was locked")
+	case ExtendVaultSession:
+		if err := writeVaultSession(key); err != nil {
+			panic(err)
+		}
+		go keepVaultSession(key)
+	}
+}
+
+// keep extending the session while a (long running) command is running so
+// the watcher never locks the vault from under it
+func keepVaultSession(key []byte) {
+	for {
+		time.Sleep(VaultTimeout / 2)
+		if !cmn.FileExists(VaultSessionFile) {
+			return
+		}
+		_ = writeVaultSession(key)
+	}
+}
+
+// StoreVaultSession encrypts the changes of an unlocked vault back into the
+// vault, run after each command which completes. A command exiting on an
+// error (log.Fatal) skips this, its changes are then only stored by the next
+// command or when the vault is locked, so are lost with the unlocked
+// directory (such as by a reboot) before then.
+func StoreVaultSession() error {
+	if !VaultMode || VaultLocked || !dirExists(VaultUnlockedDir) {
+		return nil
+	}
+	key, _, err := vaultSessionKey()
+	if err != nil {
+		return nil // locked during the command
+	}
+	return storeVault(key, VaultUnlockedDir)
+}
+
+// WatchVaultSession waits for the vault session to time out and then locks
+// the vault, returning early if the vault is locked otherwise
+func WatchVaultSession() error {
+	interval := VaultTimeout / 10
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	for {
+		key, expired, err := vaultSessionKey()
+		if err != nil || !dirExists(VaultUnlockedDir) {
+			return nil
+		}
+		if expired {
+			return lockVault(key)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func vaultSalt() (salt []byte, err error) {
+	lines, err := cmn.ReadLines(path.Join(VaultDir, vaultSaltName))
+	if err != nil || len(lines) == 0 {
+		return nil, errors.New("vault not found")
+	}
+	return hex.DecodeString(lines[0])
+}
+
+func readVaultIndex(key []byte) (entries []vaultEntry, err error) {
+	bz, err := ioutil.ReadFile(path.Join(VaultDir, vaultIndexName))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := OpenWithKey(key, bz)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(plain), "\n") {
+		if line == "" {
+			continue
+		}
+		split := strings.SplitN(line, "\t", 3)
+		if len(split) != 3 {
+			return nil, errors.New("bad vault index")
+		}
+		entries = append(entries, vaultEntry{split[0], split[1], split[2]})
+	}
+	return entries, nil
+}
+
+func writeVaultIndex(key []byte, entries []vaultEntry) error {
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, e.blob+"\t"+e.checksum+"\t"+e.relPath)
+	}
+	bz, err := SealWithKey(key, []byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	// written then renamed so the index is never partially written
+	tmpPath := path.Join(VaultDir, vaultIndexName+".tmp")
+	if err := ioutil.WriteFile(tmpPath, bz, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path.Join(VaultDir, vaultIndexName))
+}
+
+// the files within dir which are stored within the vault, relative to dir
+func vaultPaths(dir string) (relPaths []string, err error) {
+	for _, sub := range vaultDirs {
+		fis, err := ioutil.ReadDir(path.Join(dir, sub))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return relPaths, err
+		}
+		for _, fi := range fis {
+			ext := path.Ext(fi.Name())
+			if fi.IsDir() || ext == ".swp" || ext == ".swo" {
+				continue
+			}
+			relPaths = append(relPaths, path.Join(sub, fi.Name()))
+		}
+	}
+	for _, relPath := range vaultFiles {
+		if cmn.FileExists(path.Join(dir, relPath)) {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+	return relPaths, nil
+}
+
+func newVaultBlobName() (string, error) {
+	bz := make([]byte, 16)
+	if _, err := rand.Read(bz); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bz), nil
+}
+
+// store the files of dir within the vault, only files which have changed
+// since the vault was last stored are encrypted again. Blobs of files which
+// no longer exist are removed.
+func storeVault(key []byte, dir string) error {
+	prevEntries, err := readVaultIndex(key)
+	if err != nil {
+		return err
+	}
+	prev := make(map[string]vaultEntry)
+	for _, e := range prevEntries {
+		prev[e.relPath] = e
+	}
+
+	relPaths, err := vaultPaths(dir)
+	if err != nil {
+		return err
+	}
+	var entries []vaultEntry
+	kept := make(map[string]bool)
+	for _, relPath := range relPaths {
+		bz, err := ioutil.ReadFile(path.Join(dir, relPath))
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(bz)
+		checksum := hex.EncodeToString(sum[:])
+		if e, found := prev[relPath]; found && e.checksum == checksum {
+			entries = append(entries, e)
+			kept[e.blob] = true
+			continue
+		}
+		blob, err := newVaultBlobName()
+		if err != nil {
+			return err
+		}
+		sealed, err := SealWithKey(key, bz)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(path.Join(VaultDir, vaultBlobsDir, blob), sealed, 0600)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, vaultEntry{blob, checksum, relPath})
+		kept[blob] = true
+	}
+	if err := writeVaultIndex(key, entries); err != nil {
+		return err
+	}
+	for _, e := range prevEntries {
+		if !kept[e.blob] {
+			_ = os.Remove(path.Join(VaultDir, vaultBlobsDir, e.blob))
+		}
+	}
+	return nil
+}
+
+// lock the vault storing any changes and wiping the unlocked directory along
+// with the session
+func lockVault(key []byte) error {
+	if dirExists(VaultUnlockedDir) {
+		if err := storeVault(key, VaultUnlockedDir); err != nil {
+			return err
+		}
+		relPaths, err := vaultPaths(VaultUnlockedDir)
+		if err != nil {
+			return err
+		}
+		for _, relPath := range relPaths {
+			wipeFile(path.Join(VaultUnlockedDir, relPath))
+		}
+		if err := os.RemoveAll(VaultUnlockedDir); err != nil {
+			return err
+		}
+	}
+	wipeFile(VaultSessionFile)
+	return nil
+}
+
+// LockVault locks the vault of an unlocked session
+func LockVault() error {
+	if !VaultMode {
+		return errors.New("vault mode is not enabled within the thranch config (vault=true)")
+	}
+	key, _, err := vaultSessionKey()
+	if err != nil {
+		return errors.New("the vault is not unlocked")
+	}
+	return lockVault(key)
+}
+
+// UnlockVault prompts for the passphrase and decrypts the vault into the
+// unlocked directory. If the vault does not yet exist it is created from the
+// plaintext ideas of the ranch (with a confirmed passphrase) which are then
+// wiped from QuDir.
+func UnlockVault() (created bool, err error) {
+	if !VaultMode {
+		return false, errors.New("vault mode is not enabled within the thranch config (vault=true)")
+	}
+	if !vaultInMemory && !VaultDiskUnlock {
+		return false, fmt.Errorf("no memory backed directory ($XDG_RUNTIME_DIR or /dev/shm) to "+
+			"unlock the vault into, set vault-disk-unlock=true within the thranch config to "+
+			"unlock into %v on disk", os.TempDir())
+	}
+	if !cmn.FileExists(path.Join(VaultDir, vaultSaltName)) {
+		return true, createVault()
+	}
+	if !VaultLocked {
+		return false, errors.New("the vault is already unlocked")
+	}
+	salt, err := vaultSalt()
+	if err != nil {
+		return false, err
+	}
+	passphrase, err := GetPassphrase(false)
+	if err != nil {
+		return false, err
+	}
+	key := DeriveKey(passphrase, salt)
+	entries, err := readVaultIndex(key)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(VaultUnlockedDir, 0700); err != nil {
+		return false, err
+	}
+	for _, sub := range vaultDirs {
+		if err := os.MkdirAll(path.Join(VaultUnlockedDir, sub), 0700); err != nil {
+			return false, err
+		}
+	}
+	for _, e := range entries {
+		sealed, err := ioutil.ReadFile(path.Join(VaultDir, vaultBlobsDir, e.blob))
+		if err != nil {
+			return false, err
+		}
+		bz, err := OpenWithKey(key, sealed)
+		if err != nil {
+			return false, fmt.Errorf("vault blob of %v: %v", e.relPath, err)
+		}
+		err = ioutil.WriteFile(path.Join(VaultUnlockedDir, e.relPath), bz, 0600)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// relations of vaults created before relations were kept within the vault
+	plainRels := path.Join(QuDir, "relations")
+	if cmn.FileExists(plainRels) && !cmn.FileExists(path.Join(VaultUnlockedDir, "relations")) {
+		if err := cmn.Copy(plainRels, path.Join(VaultUnlockedDir, "relations")); err != nil {
+			return false, err
+		}
+		if err := storeVault(key, VaultUnlockedDir); err != nil {
+			return false, err
+		}
+		wipeFile(plainRels)
+	}
+	VaultLocked = false
+	return false, writeVaultSession(key)
+}
+
+// create the vault from the plaintext files of the ranch, the plaintext is
+// only wiped once the vault has been written
+func createVault() error {
+	passphrase, err := GetPassphrase(true)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	key := DeriveKey(passphrase, salt)
+
+	if err := os.MkdirAll(path.Join(VaultDir, vaultBlobsDir), 0700); err != nil {
+		return err
+	}
+	if err := writeVaultIndex(key, nil); err != nil {
+		return err
+	}
+	if err := storeVault(key, QuDir); err != nil {
+		return err
+	}
+	err = cmn.WriteLines([]string{hex.EncodeToString(salt)}, path.Join(VaultDir, vaultSaltName))
+	if err != nil {
+		return err
+	}
+
+	// move the plaintext into the unlocked directory
+	relPaths, err := vaultPaths(QuDir)
+	if err != nil {
+		return err
+	}
+	for _, sub := range vaultDirs {
+		if err := os.MkdirAll(path.Join(VaultUnlockedDir, sub), 0700); err != nil {
+			return err
+		}
+	}
+	for _, relPath := range relPaths {
+		err := cmn.Copy(path.Join(QuDir, relPath), path.Join(VaultUnlockedDir, relPath))
+		if err != nil {
+			return err
+		}
+		wipeFile(path.Join(QuDir, relPath))
+	}
+	for _, sub := range vaultDirs {
+		_ = os.Remove(path.Join(QuDir, sub))
+	}
+	VaultLocked = false
+	return writeVaultSession(key)
+}
+
+// files of the vault relative to QuDir, for backups
+func vaultBackupPaths() (relPaths []string, err error) {
+	fis, err := ioutil.ReadDir(path.Join(VaultDir, vaultBlobsDir))
+	if err != nil {
+		return relPaths, err
+	}
+	for _, fi := range fis {
+		relPaths = append(relPaths, path.Join("vault", vaultBlobsDir, fi.Name()))
+	}
+	return append(relPaths, path.Join("vault", vaultIndexName), path.Join("vault", vaultSaltName)), nil
+}
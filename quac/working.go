@@ -6,14 +6,19 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"path"
 	"strings"
 
 	cmn "github.com/rigelrozanski/common"
 	"github.com/rigelrozanski/thranch/quac/idea"
 )
 
-const SPLIT = "SPLIT"
+// keywords of the working files
+const (
+	SPLIT  = "SPLIT"
+	NEW    = "NEW"
+	MERGE  = "MERGE"
+	DELETE = "DELETE"
+)
 
 func WriteWorkingContentAndFilenamesFromRange(startId, endId uint32,
 	forceSplitView bool) (found bool, maxFNLen int, singleReturn string) {
@@ -45,13 +50,16 @@ func WriteWorkingContentAndFilenamesFromIdeas(idears idea.Ideas,
 		// write working contents and filenames from tags
 		var contentBz, fnBz []byte
 		for _, idear := range idears {
-			// TODO de-dup code from below
-			if !idear.IsText() {
-				continue
-			}
-			icontentBz, err := ioutil.ReadFile(idear.Path())
-			if err != nil {
-				log.Fatal(err)
+			var icontentBz []byte
+			if idear.IsText() {
+				var err error
+				icontentBz, err = ioutil.ReadFile(idear.Path())
+				if err != nil {
+					log.Fatal(err)
+				}
+			} else {
+				// only the filename of other ideas may be edited
+				icontentBz = []byte(workingPlaceholder(idear) + "\n")
 			}
 
 			noLines := bytes.Count(icontentBz, []byte{'\n'})
@@ -71,39 +79,14 @@ func WriteWorkingContentAndFilenamesFromIdeas(idears idea.Ideas,
 		if err != nil {
 			log.Fatal(err)
 		}
+		writeWorkingSnapshot(idears)
 		return true, maxFNLen, ""
 	}
 }
 
 func WriteWorkingContentAndFilenamesFromFilePath(filePath string) (maxFNLen int) {
 	idear := idea.NewIdeaFromFilepath(filePath, true)
-
-	// write working contents and filenames from tags
-	var contentBz, fnBz []byte
-	if !idear.IsText() {
-		log.Fatal("file at this idea is not text")
-	}
-	icontentBz, err := ioutil.ReadFile(idear.Path())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	noLines := bytes.Count(icontentBz, []byte{'\n'})
-
-	if len(idear.Filename)+2 > maxFNLen {
-		maxFNLen = len(idear.Filename) + 2
-	}
-	fnBz = append(fnBz, []byte(idear.Filename+strings.Repeat("\n", noLines))...)
-	contentBz = append(contentBz, icontentBz...)
-
-	err = ioutil.WriteFile(WorkingFnsFile, fnBz, os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = ioutil.WriteFile(WorkingContentFile, contentBz, os.ModePerm)
-	if err != nil {
-		log.Fatal(err)
-	}
+	_, maxFNLen, _ = WriteWorkingContentAndFilenamesFromIdeas(idea.Ideas{idear}, true)
 	return maxFNLen
 }
 
@@ -123,6 +106,8 @@ func GetOrigWorkingFileBytes() (origBzFN, origBzContent []byte) {
 	return origBzFN, origBzContent
 }
 
+// SaveFromWorkingFiles saves the line aligned working filenames and content,
+// nothing is saved if any idea within them has an error
 func SaveFromWorkingFiles(origBzFN, origBzContent []byte) {
 
 	// do not save if no modifications have been made
@@ -154,74 +139,20 @@ func SaveFromWorkingFiles(origBzFN, origBzContent []byte) {
 		os.Exit(1)
 	}
 
-	var recentFileName string
-	for startRange, fnLine := range fnLines {
-		if fnLine == "" {
-			continue
-		}
-		splitFile := false
-		if strings.HasPrefix(fnLine, SPLIT) {
-			splitFile = true
-		} else {
-			recentFileName = fnLine
-		}
-
-		endRange := startRange + 1
-		// keep adding to end unless the next line is not empty
-		// or is not a part of the array!
-		for ; !(endRange >= len(fnLines) || fnLines[endRange] != ""); endRange++ {
-		}
-
-		var origBz []byte
-		var filepath string
-
-		if splitFile {
-			if recentFileName == "" {
-				log.Fatal("cannot split with no prior filename")
-			}
-			potentialTags := strings.TrimSpace(
-				strings.TrimPrefix(fnLine, SPLIT))
-			filename := ReserveCopyFilename(recentFileName, potentialTags)
-
-			// create the split filepath but change the id
-			filepath = path.Join(idea.IdeasDir, filename)
-
-		} else {
-			// get the orig bytes (non existant if a split)
-			id, _ := idea.GetIdByFilename(fnLine)
-			found := false
-			origBz, found = GetContentByID(id)
-			if !found {
-				log.Fatal("not found when should be")
-			}
-
-			// remove the old file by id (may have been renamed)
-			RemoveByID(id)
-
-			// create the new file
-			filepath = path.Join(idea.IdeasDir, fnLine)
-		}
-
-		// do not write the file if there is no content
-		if endRange-startRange == 1 &&
-			strings.TrimSpace(contentLines[startRange]) == "" {
-			continue
-		}
-
-		// write the file
-		err := cmn.WriteLines(contentLines[startRange:endRange], filepath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		fmt.Printf("Split this out: %v\n", filepath)
-
-		// check the content and possibly mark as edited
-		finalBz, err := ioutil.ReadFile(filepath)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if bytes.Compare(origBz, finalBz) != 0 {
-			UpdateEditedDateNow(filepath)
+	// parse the working files as the single file view, reporting errors by
+	// the line of the working files
+	viewLines, srcLines := workingViewLines(fnLines, contentLines)
+	blocks, errs := ParseWorkingView(viewLines)
+	if len(errs) == 0 {
+		errs = saveWorkingBlocks(blocks)
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%v error(s) within the working files, nothing has been saved:\n", len(errs))
+		for _, err := range errs {
+			err.Line = srcLines[err.Line-1]
+			fmt.Printf("  %v\n", err)
 		}
+		fmt.Println("Correct manually with cmds: qu open-working, qu save-working")
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,466 @@
+package quac
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// confirm-working= within the thranch config, when false the changes of the
+// working files are saved without previewing them for confirmation
+var WorkingConfirm = true
+
+// The working snapshot records the filename and checksum (in the format of
+// sha256sum) of each idea as the working files are written, so that ideas
+// changed elsewhere while the editor was open are not overwritten.
+type workingSnapshot map[uint32]workingSnapshotEntry
+
+type workingSnapshotEntry struct {
+	filename string
+	checksum string
+}
+
+func writeWorkingSnapshot(idears idea.Ideas) {
+	snapshot := make(workingSnapshot)
+	snapshot.add(idears)
+	snapshot.write()
+}
+
+func (snapshot workingSnapshot) add(idears idea.Ideas) {
+	for _, idear := range idears {
+		sum, err := fileChecksum(idear.Path())
+		if err != nil {
+			log.Fatal(err)
+		}
+		snapshot[idear.Id] = workingSnapshotEntry{idear.Filename, sum}
+	}
+}
+
+func (snapshot workingSnapshot) write() {
+	var lines []string
+	for _, entry := range snapshot {
+		lines = append(lines, entry.checksum+"  "+entry.filename)
+	}
+	sort.Strings(lines)
+	if err := cmn.WriteLines(lines, WorkingSnapshotFile); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// read the working snapshot, nil if it has never been written
+func readWorkingSnapshot() workingSnapshot {
+	if !cmn.FileExists(WorkingSnapshotFile) {
+		return nil
+	}
+	lines, err := cmn.ReadLines(WorkingSnapshotFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	snapshot := make(workingSnapshot)
+	for _, line := range lines {
+		split := strings.SplitN(line, "  ", 2)
+		if len(split) != 2 {
+			continue
+		}
+		idear, err := idea.ParseFilename(split[1], false)
+		if err != nil {
+			continue
+		}
+		snapshot[idear.Id] = workingSnapshotEntry{split[1], split[0]}
+	}
+	return snapshot
+}
+
+// a change to be saved from a block of the working files
+type workingChange struct {
+	block       WorkingBlock
+	currentFn   string   // current filename of an existing idea
+	finalFn     string   // filename of an existing idea once saved
+	origContent []string // current content of an existing idea
+	content     []string // content once saved, including any merged blocks
+	renamed     bool
+	edited      bool
+	mergeInto   string // filename of the idea which a MERGE block joins
+	splitFrom   string // filename of the idea being split, blank for a new idea above
+}
+
+// saveWorkingBlocks previews the changes of the blocks, and once confirmed
+// saves them. Nothing is saved if any block has an error.
+func saveWorkingBlocks(blocks []WorkingBlock) []WorkingViewError {
+	changes, errs := planWorkingChanges(blocks)
+	if len(errs) > 0 {
+		return errs
+	}
+	if len(changes) == 0 {
+		fmt.Println("no changes to save")
+		return nil
+	}
+	if WorkingConfirm {
+		printWorkingChanges(changes)
+		if !confirm("save these changes? (Y/N)") {
+			fmt.Println("nothing saved, the working files may be saved later with cmd: qu save-working")
+			os.Exit(1)
+		}
+	}
+	applied, err := applyWorkingChanges(changes)
+	for _, line := range applied {
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Printf("error saving, only the changes above were saved: %v\n", err)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// determine the changes of the blocks, checking each existing idea against the
+// working snapshot
+func planWorkingChanges(blocks []WorkingBlock) (changes []workingChange, errs []WorkingViewError) {
+	newErr := func(block WorkingBlock, header string, format string, args ...interface{}) {
+		errs = append(errs, WorkingViewError{block.Line, header, fmt.Errorf(format, args...)})
+	}
+
+	snapshot := readWorkingSnapshot()
+	var recentFilename string
+	target := -1 // change index of the idea which a MERGE joins
+	for _, block := range blocks {
+		switch block.Kind {
+		case BlockIdea:
+			fn := block.Idea.Filename
+			recentFilename = fn
+			if block.Directive == "" {
+				target = -1
+			}
+			currentFn := GetFilenameByID(block.Idea.Id)
+			if currentFn == "" {
+				newErr(block, fn, "no idea exists with id %v", idea.IdStr(block.Idea.Id))
+				continue
+			}
+			if snapshot != nil {
+				entry, found := snapshot[block.Idea.Id]
+				if !found {
+					newErr(block, fn, "id %v was not within the working files, ids cannot be changed",
+						idea.IdStr(block.Idea.Id))
+					continue
+				}
+				if currentFn != entry.filename {
+					newErr(block, fn, "conflict, the idea was renamed to %v since the working files were written", currentFn)
+					continue
+				}
+				sum, err := fileChecksum(path.Join(idea.IdeasDir, currentFn))
+				if err != nil {
+					log.Fatal(err)
+				}
+				if sum != entry.checksum {
+					newErr(block, fn, "conflict, the idea content was changed since the working files were written")
+					continue
+				}
+			}
+			if fn != currentFn {
+				if path.Ext(fn) != path.Ext(currentFn) {
+					newErr(block, fn, "the extension cannot be changed from %q", path.Ext(currentFn))
+					continue
+				}
+				if strings.Contains(fn, ",,") || strings.ContainsAny(fn, " \t") {
+					newErr(block, fn, "invalid filename, tags cannot be empty or contain whitespace")
+					continue
+				}
+				// the filename must be exactly as the idea codec would write it
+				canonical := block.Idea
+				(&canonical).UpdateFilename()
+				if canonical.Filename != fn {
+					newErr(block, fn, "invalid filename, expected the format %v", canonical.Filename)
+					continue
+				}
+			}
+
+			change := workingChange{block: block, currentFn: currentFn, content: block.Content}
+			switch block.Directive {
+			case MERGE:
+				if target < 0 { // the idea above has an error
+					continue
+				}
+				into := &changes[target]
+				into.content = append(trimBlankEnd(into.content), block.Content...)
+				into.block.Idea.ConsumesIds = append(
+					append([]uint32{}, into.block.Idea.ConsumesIds...), block.Idea.Id)
+				change.mergeInto = into.block.Idea.Filename
+			case "":
+				target = len(changes)
+			}
+			changes = append(changes, change)
+		case BlockSplit, BlockNew:
+			target = -1 // a MERGE never joins a split or new idea
+			if isBlank(block.Content) {
+				continue
+			}
+			changes = append(changes, workingChange{block: block, splitFrom: recentFilename})
+			if block.Kind == BlockNew {
+				recentFilename = "" // split from the new idea once created
+			}
+		}
+	}
+
+	// determine the final filename and content of each existing idea, leaving
+	// out any which are unchanged
+	var final []workingChange
+	for _, change := range changes {
+		if change.block.Kind != BlockIdea || change.block.Directive == DELETE {
+			final = append(final, change)
+			continue
+		}
+		finalIdea := change.block.Idea
+		if change.block.Directive == MERGE {
+			finalIdea.Cycle = CycleConsumed
+			finalIdea.Consumed = idea.TodayDate()
+		}
+		(&finalIdea).UpdateFilename()
+		change.finalFn = finalIdea.Filename
+		change.renamed = change.finalFn != change.currentFn
+
+		origBz, err := ioutil.ReadFile(path.Join(idea.IdeasDir, change.currentFn))
+		if err != nil {
+			log.Fatal(err)
+		}
+		finalBz := []byte(strings.Join(change.content, "\n"))
+		change.origContent = strings.Split(strings.TrimRight(string(origBz), "\n"), "\n")
+		if change.block.Directive == "" && change.block.Idea.IsText() {
+			change.edited = !bytes.Equal(bytes.TrimRight(origBz, "\n"), bytes.TrimRight(finalBz, "\n"))
+		}
+		if change.renamed || change.edited {
+			final = append(final, change)
+		}
+	}
+	return final, errs
+}
+
+// print a preview of the changes to be saved
+func printWorkingChanges(changes []workingChange) {
+	for _, change := range changes {
+		block := change.block
+		switch block.Kind {
+		case BlockIdea:
+			switch {
+			case block.Directive == DELETE:
+				fmt.Printf("delete: %v\n", change.currentFn)
+				continue
+			case block.Directive == MERGE:
+				fmt.Printf("merge:  %v\n     -> into %v\n", change.currentFn, change.mergeInto)
+				continue
+			case change.renamed:
+				fmt.Printf("rename: %v\n     -> %v\n", change.currentFn, change.finalFn)
+			}
+			if change.edited {
+				fmt.Printf("edit:   %v\n", change.finalFn)
+				for _, line := range diffLines(change.origContent, trimBlankEnd(change.content)) {
+					fmt.Printf("    %v\n", line)
+				}
+			}
+		case BlockSplit:
+			from := change.splitFrom
+			if from == "" {
+				from = "the new idea above"
+			}
+			fmt.Printf("split:  %v lines from %v", len(trimBlankEnd(block.Content)), from)
+			if block.Tags != "" {
+				fmt.Printf(" adding tags %v", block.Tags)
+			}
+			fmt.Println()
+		case BlockNew:
+			fmt.Printf("new:    %v lines tagged %v\n", len(trimBlankEnd(block.Content)), block.Tags)
+		}
+	}
+}
+
+func trimBlankEnd(lines []string) []string {
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maximum number of line comparisons for a diff, larger diffs simply show all
+// of the removed and added lines
+const maxDiffCells = 4000000
+
+// diffLines returns the lines removed (prefixed with "- ") and added (prefixed
+// with "+ ") from a to b, based on their longest common subsequence
+func diffLines(a, b []string) (diff []string) {
+
+	// trim the common prefix and suffix
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a)*len(b) > maxDiffCells {
+		for _, line := range a {
+			diff = append(diff, "- "+line)
+		}
+		for _, line := range b {
+			diff = append(diff, "+ "+line)
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// apply the changes, the working snapshot is then updated to the saved ideas.
+// All new and edited content is written before any idea is renamed or
+// trashed, so an error while writing leaves every existing idea as it was.
+// The changes applied before any error are returned.
+func applyWorkingChanges(changes []workingChange) (applied []string, err error) {
+	var savedIds []uint32
+	defer func() { updateWorkingSnapshot(savedIds) }()
+
+	// write the new ideas and stage the edited content
+	stageDir := path.Dir(idea.IdeasDir) // alongside the ideas for the renames
+	staged := make(map[int]string)      // staged content path by change index
+	defer func() {
+		for _, stagePath := range staged {
+			_ = os.Remove(stagePath)
+		}
+	}()
+	var newFilename string
+	for i, change := range changes {
+		block := change.block
+		switch block.Kind {
+		case BlockIdea:
+			if !change.edited || block.Directive == DELETE {
+				continue
+			}
+			stagePath := path.Join(stageDir, "working_save_"+idea.IdStr(block.Idea.Id))
+			if err := cmn.WriteLines(change.content, stagePath); err != nil {
+				return applied, err
+			}
+			staged[i] = stagePath
+		case BlockSplit, BlockNew:
+			var filename string
+			if block.Kind == BlockSplit {
+				from := change.splitFrom
+				if from == "" {
+					from = newFilename
+				}
+				filename = ReserveCopyFilename(from, block.Tags)
+
+				// split content is text, even when split from an image
+				if splitIdea := idea.NewIdeaFromFilename(filename, false); !splitIdea.IsText() {
+					splitIdea.Kind, splitIdea.Ext = idea.KindText, ""
+					(&splitIdea).UpdateFilename()
+					filename = splitIdea.Filename
+				}
+			} else {
+				filename = idea.NewNonConsumingTextIdea(block.Tags).Filename
+				idea.IncrementID()
+			}
+			filepath := path.Join(idea.IdeasDir, filename)
+			if err := cmn.WriteLines(block.Content, filepath); err != nil {
+				return applied, err
+			}
+			if block.Kind == BlockSplit {
+				applied = append(applied, "Split this out: "+filepath)
+			} else {
+				applied = append(applied, "New idea: "+filepath)
+				newFilename = filename
+			}
+			savedIds = append(savedIds, idea.NewIdeaFromFilename(filename, false).Id)
+		}
+	}
+	// rename and trash the existing ideas, an edited idea is trashed (keeping
+	// its prior content) and replaced by its staged content
+	for i, change := range changes {
+		block := change.block
+		if block.Kind != BlockIdea {
+			continue
+		}
+		currentFilepath := path.Join(idea.IdeasDir, change.currentFn)
+		switch {
+		case block.Directive == DELETE:
+			if err := trashByID(block.Idea.Id); err != nil {
+				return applied, err
+			}
+			applied = append(applied, "Trashed: "+currentFilepath)
+		case change.edited:
+			edited := idea.NewIdeaFromFilename(change.finalFn, false)
+			edited.Edited = idea.TodayDate()
+			(&edited).UpdateFilename()
+			if err := trashByID(block.Idea.Id); err != nil {
+				return applied, err
+			}
+			if err := os.Rename(staged[i], edited.Path()); err != nil {
+				return applied, fmt.Errorf("%v, the prior content of %v is within the trash can",
+					err, change.currentFn)
+			}
+			applied = append(applied, "Saved: "+edited.Path())
+		default:
+			filepath := path.Join(idea.IdeasDir, change.finalFn)
+			if err := os.Rename(currentFilepath, filepath); err != nil {
+				return applied, err
+			}
+			applied = append(applied, "Renamed: "+filepath)
+		}
+		savedIds = append(savedIds, block.Idea.Id)
+	}
+	return applied, nil
+}
+
+// update the working snapshot to the saved ideas, the ideas which were not
+// changed keep their snapshot
+func updateWorkingSnapshot(savedIds []uint32) {
+	snapshot := readWorkingSnapshot()
+	if snapshot == nil {
+		snapshot = make(workingSnapshot)
+	}
+	var idears idea.Ideas
+	for _, id := range savedIds {
+		fn := GetFilenameByID(id)
+		if fn == "" { // deleted
+			delete(snapshot, id)
+			continue
+		}
+		idears = append(idears, idea.NewIdeaFromFilename(fn, false))
+	}
+	snapshot.add(idears)
+	snapshot.write()
+}
@@ -0,0 +1,164 @@
+package quac
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+const (
+	testWorkingFn1 = "a,000001,2021-03-07,e2021-03-07,foo"
+	testWorkingFn2 = "a,000002,2021-03-07,e2021-03-07,bar"
+)
+
+// write two ideas along with the working snapshot of them
+func setupWorkingSave(t *testing.T) (dir string) {
+	dir = setupWorkingTest(t)
+	idea.IdeasDir, TrashCanDir = path.Join(dir, "ideas"), path.Join(dir, "trash")
+	WorkingSnapshotFile = path.Join(dir, "working_snapshot")
+	for _, d := range []string{idea.IdeasDir, TrashCanDir} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestIdea(t, testWorkingFn1, "one\ntwo\nthree\n")
+	writeTestIdea(t, testWorkingFn2, "other\n")
+	writeWorkingSnapshot(idea.Ideas{
+		idea.NewIdeaFromFilename(testWorkingFn1, false),
+		idea.NewIdeaFromFilename(testWorkingFn2, false),
+	})
+	return dir
+}
+
+func writeTestIdea(t *testing.T, fn, content string) {
+	if err := ioutil.WriteFile(path.Join(idea.IdeasDir, fn), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func planTestView(t *testing.T, view string) ([]workingChange, []WorkingViewError) {
+	blocks, errs := ParseWorkingView(strings.Split(view, "\n"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected parse errors %v", errs)
+	}
+	return planWorkingChanges(blocks)
+}
+
+func TestPlanWorkingChangesConflicts(t *testing.T) {
+	defer os.RemoveAll(setupWorkingSave(t))
+	view := "==> " + testWorkingFn1 + " <==\none\n2\nthree\n==> " + testWorkingFn2 + " <==\nother"
+
+	changes, errs := planTestView(t, view)
+	if len(errs) != 0 || len(changes) != 1 || !changes[0].edited {
+		t.Fatalf("expected a single edit, got %v and %v", changes, errs)
+	}
+
+	// renamed and edited elsewhere since the working files were written
+	renamed := strings.Replace(testWorkingFn1, "foo", "baz", 1)
+	if err := os.Rename(path.Join(idea.IdeasDir, testWorkingFn1), path.Join(idea.IdeasDir, renamed)); err != nil {
+		t.Fatal(err)
+	}
+	writeTestIdea(t, testWorkingFn2, "changed elsewhere\n")
+	_, errs = planTestView(t, view)
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "was renamed to "+renamed) ||
+		!strings.Contains(errs[1].Error(), "content was changed") {
+		t.Errorf("expected rename and content conflicts, got %v", errs)
+	}
+}
+
+func TestPlanWorkingChangesFilenames(t *testing.T) {
+	defer os.RemoveAll(setupWorkingSave(t))
+
+	cases := []struct {
+		fn     string
+		errMsg string
+	}{
+		{"a,000001,2021-03-07,e2021-03-07,foo,baz", ""},
+		{"a,000001,2021-03-07,e2021-03-07,c2021-03-08,foo", "expected the format " + testWorkingFn1},
+		{"a,000001,2021-03-07,e2021-03-07,foo.png", "extension cannot be changed"},
+	}
+	for _, c := range cases {
+		content := "one\ntwo\nthree"
+		if idear := idea.NewIdeaFromFilename(c.fn, false); !idear.IsText() {
+			content = workingPlaceholder(idear)
+		}
+		changes, errs := planTestView(t, "==> "+c.fn+" <==\n"+content)
+		switch {
+		case c.errMsg == "" && (len(errs) != 0 || len(changes) != 1 || !changes[0].renamed):
+			t.Errorf("%v: expected a rename, got %v and %v", c.fn, changes, errs)
+		case c.errMsg != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), c.errMsg)):
+			t.Errorf("%v: expected an error containing %q, got %v", c.fn, c.errMsg, errs)
+		}
+	}
+}
+
+func TestApplyWorkingMerge(t *testing.T) {
+	defer os.RemoveAll(setupWorkingSave(t))
+	changes, errs := planTestView(t, "==> "+testWorkingFn1+" <==\none\ntwo\nthree\n==> MERGE "+
+		testWorkingFn2+" <==\nother")
+	if len(errs) != 0 || len(changes) != 2 {
+		t.Fatalf("expected two changes, got %v and %v", changes, errs)
+	}
+	if _, err := applyWorkingChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := GetIdeaByID(1, false)
+	if len(merged.ConsumesIds) != 1 || merged.ConsumesIds[0] != 2 {
+		t.Errorf("expected the merged idea to consume 000002, got %v", merged.Filename)
+	}
+	if content := string(merged.GetContent()); content != "one\ntwo\nthree\nother\n" {
+		t.Errorf("unexpected merged content %q", content)
+	}
+	if consumed := GetIdeaByID(2, false); consumed.Cycle != CycleConsumed {
+		t.Errorf("expected 000002 to be consumed, got %v", consumed.Filename)
+	}
+}
+
+func TestApplyWorkingPartialSave(t *testing.T) {
+	dir := setupWorkingSave(t)
+	defer os.RemoveAll(dir)
+	changes, errs := planTestView(t, "==> "+testWorkingFn1+" <==\none\n==> "+testWorkingFn2+" <==\nedited")
+	if len(errs) != 0 || len(changes) != 2 {
+		t.Fatalf("expected two changes, got %v and %v", changes, errs)
+	}
+
+	// the edited ideas cannot be trashed, so neither is replaced
+	if err := os.Remove(TrashCanDir); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := applyWorkingChanges(changes)
+	if err == nil || len(applied) != 0 {
+		t.Fatalf("expected an error with nothing applied, got %v and %v", applied, err)
+	}
+	for fn, content := range map[string]string{testWorkingFn1: "one\ntwo\nthree\n", testWorkingFn2: "other\n"} {
+		if bz, err := ioutil.ReadFile(path.Join(idea.IdeasDir, fn)); err != nil || string(bz) != content {
+			t.Errorf("expected %v to be unchanged, got %q (%v)", fn, bz, err)
+		}
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), "working_save_") {
+			t.Errorf("expected the staged content to be removed, found %v", fi.Name())
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"one", "two", "three", "four"}
+	b := []string{"one", "2", "three", "four", "five"}
+	expected := []string{"- two", "+ 2", "+ five"}
+	if diff := diffLines(a, b); strings.Join(diff, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected diff %q, got %q", expected, diff)
+	}
+	if diff := diffLines(a, a); len(diff) != 0 {
+		t.Errorf("expected no diff, got %q", diff)
+	}
+}
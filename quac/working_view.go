@@ -0,0 +1,397 @@
+package quac
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// The single file working view holds each idea's content beneath a header
+// line holding its filename. Headers for new ideas may be added inline, a
+// SPLIT header splits out the following content into a copy of the idea above
+// (with any additional tags) and a NEW header creates an idea with only the
+// provided tags. Prefixing the filename of a header with MERGE joins the block
+// into the existing idea directly above (which then consumes it) and with
+// DELETE sends the idea to the trash. Ideas which are not text (images, audio
+// and encrypted ideas) are shown with a placeholder in place of their
+// content, only their filename may be edited. Content lines which would be
+// read as a header are escaped with a leading backslash, which is removed
+// when the view is read back:
+//
+//	==> a,000123,2021-03-07,e2021-03-07,foo <==
+//	content of the idea
+//	==> SPLIT bar <==
+//	content split into a new idea tagged foo,bar
+//	==> NEW baz <==
+//	content of a new idea tagged baz
+//	==> MERGE a,000124,2021-03-07,e2021-03-07,foo <==
+//	content joined to the end of idea 000123
+//	==> DELETE a,000125,2021-03-07,e2021-03-07,foo <==
+//	\==> content which looks like a header <==
+const (
+	workingViewHeaderPrefix = "==> "
+	workingViewHeaderSuffix = " <=="
+)
+
+// working-view= within the thranch config, either "split" (the default) or
+// "single". The single file view is also used when the editor cannot split.
+var WorkingViewMode string
+
+// UseWorkingView returns true if the single file working view should be used
+// rather than the side by side working filenames and content
+func UseWorkingView() bool {
+	if WorkingViewMode == "single" {
+		return true
+	}
+	_, ok := GetEditor().(SplitEditor)
+	return !ok
+}
+
+// the content of a block of an idea which is not text
+func workingPlaceholder(idear idea.Idea) string {
+	return fmt.Sprintf("[%v idea, only the filename may be edited here]", idea.KindName(idear.Kind))
+}
+
+func workingViewHeader(fnLine string) string {
+	return workingViewHeaderPrefix + fnLine + workingViewHeaderSuffix
+}
+
+// the filename (or SPLIT line) of a header line of the working view
+func parseWorkingViewHeader(line string) (fnLine string, isHeader bool) {
+	if !strings.HasPrefix(line, workingViewHeaderPrefix) ||
+		!strings.HasSuffix(line, workingViewHeaderSuffix) {
+		return "", false
+	}
+	fnLine = strings.TrimSuffix(strings.TrimPrefix(line, workingViewHeaderPrefix), workingViewHeaderSuffix)
+	return strings.TrimSpace(fnLine), true
+}
+
+// escape a content line which would otherwise be read as a header, lines
+// which are already escaped are escaped again so they read back unchanged
+func escapeWorkingViewLine(line string) string {
+	if _, isHeader := parseWorkingViewHeader(strings.TrimLeft(line, `\`)); isHeader {
+		return `\` + line
+	}
+	return line
+}
+
+func unescapeWorkingViewLine(line string) string {
+	if !strings.HasPrefix(line, `\`) {
+		return line
+	}
+	if _, isHeader := parseWorkingViewHeader(strings.TrimLeft(line, `\`)); isHeader {
+		return line[1:]
+	}
+	return line
+}
+
+// EditWorkingFiles edits the working files which have just been written from
+// the ideas and then saves them
+func EditWorkingFiles(maxFNLen int) {
+	if !UseWorkingView() {
+		origBzFN, origBzContent := GetOrigWorkingFileBytes()
+		OpenTextSplit(WorkingFnsFile, WorkingContentFile, maxFNLen)
+		SaveFromWorkingFiles(origBzFN, origBzContent)
+		return
+	}
+	err := WriteWorkingView(WorkingFnsFile, WorkingContentFile, WorkingViewFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	origBz, err := ioutil.ReadFile(WorkingViewFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	line := 1
+	for {
+		if err := GetEditor().Open(WorkingViewFile, line); err != nil {
+			log.Fatal(err)
+		}
+		finalBz, err := ioutil.ReadFile(WorkingViewFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if bytes.Equal(origBz, finalBz) {
+			return
+		}
+		errs := SaveWorkingView()
+		if len(errs) == 0 {
+			return
+		}
+		printWorkingViewErrors(errs)
+		if !confirm("reopen the working view to correct these errors? (Y/N)") {
+			fmt.Println("nothing saved, correct manually with cmds: qu open-working, qu save-working")
+			os.Exit(1)
+		}
+		line = errs[0].Line
+	}
+}
+
+func printWorkingViewErrors(errs []WorkingViewError) {
+	fmt.Printf("%v error(s) within the working view, nothing has been saved:\n", len(errs))
+	for _, err := range errs {
+		fmt.Printf("  %v\n", err)
+	}
+}
+
+// read a Y/N confirmation from the console
+func confirm(prompt string) bool {
+	fmt.Println(prompt)
+	consoleScanner := bufio.NewScanner(os.Stdin)
+	_ = consoleScanner.Scan()
+	in := strings.TrimSpace(consoleScanner.Text())
+	return in == "Y" || in == "y"
+}
+
+// OpenWorkingView edits the working filenames and content as a single file
+// working view, which is then converted back into the working files
+func OpenWorkingView(fnsPath, contentPath string) {
+	err := WriteWorkingView(fnsPath, contentPath, WorkingViewFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	EditText(WorkingViewFile)
+	err = ReadWorkingView(WorkingViewFile, fnsPath, contentPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// WriteWorkingView combines the line aligned working files into the view
+func WriteWorkingView(fnsPath, contentPath, viewPath string) error {
+	fnLines, err := cmn.ReadLines(fnsPath)
+	if err != nil {
+		return err
+	}
+	contentLines, err := cmn.ReadLines(contentPath)
+	if err != nil {
+		return err
+	}
+	if len(fnLines) != len(contentLines) {
+		return fmt.Errorf("unequal number of lines in working files (%v and %v)",
+			len(fnLines), len(contentLines))
+	}
+	viewLines, _ := workingViewLines(fnLines, contentLines)
+	return cmn.WriteLines(viewLines, viewPath)
+}
+
+// combine the line aligned working filenames and content into the lines of
+// the view, along with the working file line (starting at 1) of each view line
+func workingViewLines(fnLines, contentLines []string) (viewLines []string, srcLines []int) {
+	for i, fnLine := range fnLines {
+		if fnLine != "" {
+			viewLines = append(viewLines, workingViewHeader(fnLine))
+			srcLines = append(srcLines, i+1)
+		}
+		viewLines = append(viewLines, escapeWorkingViewLine(contentLines[i]))
+		srcLines = append(srcLines, i+1)
+	}
+	return viewLines, srcLines
+}
+
+// ReadWorkingView splits the view back into line aligned working files, the
+// filename of each block is placed on the line of its first content line
+func ReadWorkingView(viewPath, fnsPath, contentPath string) error {
+	viewLines, err := cmn.ReadLines(viewPath)
+	if err != nil {
+		return err
+	}
+	var fnLines, contentLines []string
+	pending := ""
+	for _, line := range viewLines {
+		if fnLine, isHeader := parseWorkingViewHeader(line); isHeader {
+			if pending != "" { // block without content
+				fnLines = append(fnLines, pending)
+				contentLines = append(contentLines, "")
+			}
+			pending = fnLine
+			continue
+		}
+		fnLines = append(fnLines, pending)
+		contentLines = append(contentLines, unescapeWorkingViewLine(line))
+		pending = ""
+	}
+	if pending != "" {
+		fnLines = append(fnLines, pending)
+		contentLines = append(contentLines, "")
+	}
+	if err := cmn.WriteLines(fnLines, fnsPath); err != nil {
+		return err
+	}
+	return cmn.WriteLines(contentLines, contentPath)
+}
+
+// kinds of working view blocks
+const (
+	BlockIdea  = iota // an existing idea
+	BlockSplit        // split into a copy of the previous idea
+	BlockNew          // a new idea
+)
+
+// WorkingBlock is a header and its content within the working view
+type WorkingBlock struct {
+	Line      int // line number of the header (starting at 1)
+	Kind      int
+	Idea      idea.Idea // parsed filename of an existing idea
+	Directive string    // MERGE or DELETE of an existing idea
+	Tags      string    // clumped tags of a SPLIT or NEW block
+	Content   []string
+}
+
+// WorkingViewError is an error within a single block of the working view
+type WorkingViewError struct {
+	Line   int
+	Header string
+	Err    error
+}
+
+func (e WorkingViewError) Error() string {
+	if e.Header == "" {
+		return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %v (%v): %v", e.Line, e.Header, e.Err)
+}
+
+// ParseWorkingView parses the lines of the working view into blocks, any
+// errors are returned for each offending block rather than stopping the parse
+func ParseWorkingView(lines []string) (blocks []WorkingBlock, errs []WorkingViewError) {
+	newErr := func(line int, header string, format string, args ...interface{}) {
+		errs = append(errs, WorkingViewError{line, header, fmt.Errorf(format, args...)})
+	}
+
+	// the first header must come before any content
+	start := 0
+	for ; start < len(lines); start++ {
+		if _, isHeader := parseWorkingViewHeader(lines[start]); isHeader {
+			break
+		}
+		if strings.TrimSpace(lines[start]) != "" {
+			newErr(start+1, "", "content before the first header")
+			break
+		}
+	}
+
+	ids := make(map[uint32]int) // id -> header line
+	haveIdea := false           // whether a SPLIT has an idea to copy above
+	aboveDeleted := false       // whether a SPLIT would copy an idea being deleted
+	haveTarget := false         // whether a MERGE has a text idea to join above
+	for i := start; i < len(lines); i++ {
+		header, isHeader := parseWorkingViewHeader(lines[i])
+		if !isHeader {
+			continue
+		}
+		block := WorkingBlock{Line: i + 1}
+		for j := i + 1; j < len(lines); j++ {
+			if _, isHeader := parseWorkingViewHeader(lines[j]); isHeader {
+				break
+			}
+			block.Content = append(block.Content, unescapeWorkingViewLine(lines[j]))
+		}
+
+		keyword := strings.SplitN(header, " ", 2)[0]
+		switch keyword {
+		case SPLIT, NEW:
+			block.Kind = BlockSplit
+			if keyword == NEW {
+				block.Kind = BlockNew
+			}
+			block.Tags = strings.TrimSpace(strings.TrimPrefix(header, keyword))
+			tags, err := idea.ParseClumpedTagsErr(block.Tags)
+
+			// a MERGE only joins an existing idea directly above
+			haveTarget = false
+			switch {
+			case err != nil:
+				newErr(block.Line, header, "bad tags: %v", err)
+				continue
+			case keyword == NEW && len(tags) == 0:
+				newErr(block.Line, header, "a new idea requires tags, for example \"NEW foo,bar\"")
+				continue
+			case keyword == SPLIT && !haveIdea:
+				newErr(block.Line, header, "cannot split with no idea above")
+				continue
+			case keyword == SPLIT && aboveDeleted:
+				newErr(block.Line, header, "cannot split from an idea being deleted")
+				continue
+			case keyword == NEW:
+				aboveDeleted = false
+			}
+		default:
+			block.Kind = BlockIdea
+			fn := header
+			if keyword == MERGE || keyword == DELETE {
+				block.Directive = keyword
+				fn = strings.TrimSpace(strings.TrimPrefix(header, keyword))
+			}
+			idear, err := idea.ParseFilename(fn, false)
+			if err != nil {
+				newErr(block.Line, header, "%v", err)
+				continue
+			}
+			if prev, found := ids[idear.Id]; found {
+				newErr(block.Line, header, "id %v already used at line %v", idea.IdStr(idear.Id), prev)
+				continue
+			}
+			placeholder := !idear.IsText() && block.Directive != DELETE
+			switch {
+			case block.Directive == MERGE && !idear.IsText():
+				newErr(block.Line, header, "only text ideas may be merged")
+				continue
+			case block.Directive == MERGE && !haveTarget:
+				newErr(block.Line, header, "cannot merge with no existing text idea directly above")
+				continue
+			case placeholder && !isPlaceholder(block.Content, idear):
+				newErr(block.Line, header, "the content of %v ideas cannot be edited, leave it as: %v",
+					idea.KindName(idear.Kind), workingPlaceholder(idear))
+				continue
+			case block.Directive == "" && isBlank(block.Content):
+				newErr(block.Line, header, "idea has no content, use DELETE to remove the idea")
+				continue
+			case block.Directive == "":
+				haveTarget = idear.IsText()
+			}
+			aboveDeleted = block.Directive == DELETE
+			ids[idear.Id] = block.Line
+			block.Idea = idear
+		}
+		haveIdea = true
+		blocks = append(blocks, block)
+	}
+	return blocks, errs
+}
+
+// whether the content is only the placeholder of the idea
+func isPlaceholder(content []string, idear idea.Idea) bool {
+	content = trimBlankEnd(content)
+	return len(content) == 1 && strings.TrimSpace(content[0]) == workingPlaceholder(idear)
+}
+
+func isBlank(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveWorkingView parses and saves the working view. Nothing is saved if any
+// block has an error.
+func SaveWorkingView() []WorkingViewError {
+	lines, err := cmn.ReadLines(WorkingViewFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	blocks, errs := ParseWorkingView(lines)
+	if len(errs) > 0 {
+		return errs
+	}
+	return saveWorkingBlocks(blocks)
+}
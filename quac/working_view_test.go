@@ -0,0 +1,136 @@
+package quac
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	cmn "github.com/rigelrozanski/common"
+	"github.com/rigelrozanski/thranch/quac/idea"
+)
+
+// ids are parsed against the last ids file
+func setupWorkingTest(t *testing.T) (dir string) {
+	dir, err := ioutil.TempDir("", "working")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idea.LastIdFile = path.Join(dir, "last")
+	if err := ioutil.WriteFile(idea.LastIdFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestParseWorkingView(t *testing.T) {
+	defer os.RemoveAll(setupWorkingTest(t))
+
+	const (
+		fn1 = "a,000001,2021-03-07,e2021-03-07,foo"
+		fn2 = "a,000002,2021-03-07,e2021-03-07,bar"
+		img = "a,000003,2021-03-07,e2021-03-07,pic.png"
+	)
+	cases := []struct {
+		name   string
+		view   string
+		kinds  []int
+		errAt  []int  // lines of the expected errors
+		errMsg string // within the first error
+	}{
+		{"ideas", "==> " + fn1 + " <==\none\n==> " + fn2 + " <==\ntwo",
+			[]int{BlockIdea, BlockIdea}, nil, ""},
+		{"split and new", "==> " + fn1 + " <==\none\n==> SPLIT baz <==\nsplit\n==> NEW qux <==\nnew",
+			[]int{BlockIdea, BlockSplit, BlockNew}, nil, ""},
+		{"escaped header", "==> " + fn1 + " <==\n\\==> notes.txt <==\none",
+			[]int{BlockIdea}, nil, ""},
+		{"unescaped header", "==> " + fn1 + " <==\n==> notes.txt <==\none",
+			nil, []int{1, 2}, "no content"},
+		{"content first", "stray\n==> " + fn1 + " <==\none",
+			[]int{BlockIdea}, []int{1}, "before the first header"},
+		{"bad filename", "==> a,bad <==\none\n==> " + fn2 + " <==\ntwo",
+			[]int{BlockIdea}, []int{1}, "bad filename"},
+		{"duplicate id", "==> " + fn1 + " <==\none\n==> " + strings.Replace(fn1, "foo", "baz", 1) + " <==\ntwo",
+			[]int{BlockIdea}, []int{3}, "already used at line 1"},
+		{"split first", "==> SPLIT baz <==\none", nil, []int{1}, "no idea above"},
+		{"new without tags", "==> " + fn1 + " <==\none\n==> NEW <==\ntwo",
+			[]int{BlockIdea}, []int{3}, "requires tags"},
+		{"placeholder edited", "==> " + img + " <==\nsome text",
+			nil, []int{1}, "cannot be edited"},
+		{"merge under new", "==> " + fn1 + " <==\none\n==> NEW baz <==\nnew\n==> MERGE " + fn2 + " <==\ntwo",
+			[]int{BlockIdea, BlockNew}, []int{5}, "directly above"},
+		{"merge under merge", "==> " + fn1 + " <==\none\n==> MERGE " + fn2 + " <==\ntwo\n==> MERGE " +
+			strings.Replace(fn2, "000002", "000004", 1) + " <==\nfour",
+			[]int{BlockIdea, BlockIdea, BlockIdea}, nil, ""},
+		{"split under delete", "==> " + fn1 + " <==\none\n==> DELETE " + fn2 + " <==\n==> SPLIT baz <==\nsplit",
+			[]int{BlockIdea, BlockIdea}, []int{4}, "being deleted"},
+		{"placeholder", "==> " + img + " <==\n[image idea, only the filename may be edited here]",
+			[]int{BlockIdea}, nil, ""},
+	}
+	for _, c := range cases {
+		blocks, errs := ParseWorkingView(strings.Split(c.view, "\n"))
+		var kinds, errAt []int
+		for _, b := range blocks {
+			kinds = append(kinds, b.Kind)
+		}
+		for _, err := range errs {
+			errAt = append(errAt, err.Line)
+		}
+		if !equalInts(kinds, c.kinds) || !equalInts(errAt, c.errAt) {
+			t.Errorf("%v: expected blocks %v and errors at %v, got %v and %v",
+				c.name, c.kinds, c.errAt, kinds, errs)
+			continue
+		}
+		if c.errMsg != "" && !strings.Contains(errs[0].Error(), c.errMsg) {
+			t.Errorf("%v: expected an error containing %q, got %v", c.name, c.errMsg, errs[0])
+		}
+	}
+
+	// escaped content is read back without the escape
+	blocks, _ := ParseWorkingView([]string{"==> " + fn1 + " <==", `\==> notes.txt <==`, `\\==> x <==`})
+	if len(blocks) != 1 || strings.Join(blocks[0].Content, "\n") != "==> notes.txt <==\n\\==> x <==" {
+		t.Errorf("unexpected unescaped content %q", blocks[0].Content)
+	}
+}
+
+func TestWorkingViewRoundTrip(t *testing.T) {
+	dir := setupWorkingTest(t)
+	defer os.RemoveAll(dir)
+
+	fnsPath, contentPath := path.Join(dir, "fns"), path.Join(dir, "content")
+	viewPath := path.Join(dir, "view")
+	fns := []string{"a,000001,2021-03-07,e2021-03-07,foo", "", "", "a,000002,2021-03-07,e2021-03-07,bar"}
+	content := []string{"==> notes.txt <==", `\==> x <==`, "plain", "two"}
+	if err := cmn.WriteLines(fns, fnsPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmn.WriteLines(content, contentPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteWorkingView(fnsPath, contentPath, viewPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadWorkingView(viewPath, fnsPath, contentPath); err != nil {
+		t.Fatal(err)
+	}
+	gotFns, _ := cmn.ReadLines(fnsPath)
+	gotContent, _ := cmn.ReadLines(contentPath)
+	if strings.Join(gotFns, "\n") != strings.Join(fns, "\n") ||
+		strings.Join(gotContent, "\n") != strings.Join(content, "\n") {
+		t.Errorf("working files changed by the round trip:\n%q\n%q", gotFns, gotContent)
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}